@@ -0,0 +1,416 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"reilabs/whir-verifier-circuit/utilities"
+	"reilabs/whir-verifier-circuit/whir"
+)
+
+type inputFlags struct {
+	proof      *string
+	params     *string
+	r1cs       *string
+	public     *string
+	transcript *string
+	backend    *string
+	universal  whir.UniversalBounds
+	shapes     *string
+	commit     *bool
+	naiveFolds *bool
+	pow        *string
+	merkle     *string
+	shared     *bool
+}
+
+func addInputFlags(fs *flag.FlagSet) *inputFlags {
+	in := &inputFlags{
+		proof:      fs.String("proof", "", "path to the WHIR proof produced by the ProveKit prover"),
+		params:     fs.String("params", "", "path to the WHIR params file produced by the ProveKit prover"),
+		r1cs:       fs.String("r1cs", "", "path to the r1cs.json the proof was produced for"),
+		public:     fs.String("public-inputs", "", "path to a JSON array of the decimal values of the r1cs public inputs, required when the r1cs has any"),
+		transcript: fs.String("transcript", string(whir.TranscriptPublic), "how the transcript is made public: public, or a skyscraper or keccak digest of it"),
+		backend:    fs.String("backend", string(whir.BackendGroth16), "proof system to wrap the WHIR proof with: groth16 or plonk"),
+	}
+	in.naiveFolds = fs.Bool("naive-folds", false, "verify a proof whose leaves hold coset evaluations instead of fold coefficients (WHIR FoldType::Naive)")
+	in.pow = fs.String("pow", "threshold", "how field proof of work hashes are checked: threshold, below the modulus shifted by the difficulty, or leading-zeros, starting with that many zero bits; a keccak pow_hash always follows nimue-pow")
+	in.merkle = fs.String("merkle", "", "hash of the merkle trees the WHIR proof commits with, overriding the merkle_hash of the params: skyscraper, keccak, mimc or poseidon2")
+	in.shared = fs.Bool("shared-nodes", false, "hash every node of the merkle paths once; the leaf indexes of the proof are compiled in, so the setup verifies this one proof only")
+	in.commit = fs.Bool("commit-matrices", false, "make the matrices of the universal circuit private and expose only their digest")
+	fs.IntVar(&in.universal.LogConstraints, "universal-log-constraints", 0, "build the universal circuit for r1cs of at most 2^n constraints")
+	fs.IntVar(&in.universal.LogVars, "universal-log-vars", 0, "build the universal circuit for witnesses of at most 2^n entries")
+	fs.IntVar(&in.universal.NonZeros, "universal-nonzeros", 0, "build the universal circuit for matrices with at most n entries each")
+	in.shapes = fs.String("universal-params", "", "comma separated params files of the instance sizes the universal circuit admits, by default the size of -params only")
+	return in
+}
+
+func (in *inputFlags) options() ([]whir.Option, error) {
+	mode, err := whir.ParseTranscriptMode(*in.transcript)
+	if err != nil {
+		return nil, err
+	}
+	backend, err := whir.ParseBackend(*in.backend)
+	if err != nil {
+		return nil, err
+	}
+	pow, err := utilities.ParsePoWMode(*in.pow)
+	if err != nil {
+		return nil, err
+	}
+	opts := []whir.Option{whir.WithTranscriptMode(mode), whir.WithBackend(backend), whir.WithPoWMode(pow)}
+	if *in.merkle != "" {
+		merkle, err := whir.ParseHash(*in.merkle)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, whir.WithMerkleHash(merkle))
+	}
+	if *in.shapes != "" {
+		shapes, err := whir.LoadShapes(strings.Split(*in.shapes, ","))
+		if err != nil {
+			return nil, err
+		}
+		in.universal.Shapes = shapes
+	}
+	if in.universal.LogConstraints != 0 || in.universal.LogVars != 0 || in.universal.NonZeros != 0 || in.universal.Shapes != nil {
+		opts = append(opts, whir.WithUniversal(in.universal))
+	}
+	if *in.commit {
+		opts = append(opts, whir.WithCommittedMatrices())
+	}
+	if *in.naiveFolds {
+		opts = append(opts, whir.WithNaiveFolds())
+	}
+	if *in.shared {
+		opts = append(opts, whir.WithSharedNodes())
+	}
+	if *in.public != "" {
+		values, err := whir.LoadPublicInputs(*in.public)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, whir.WithPublicInputs(values))
+	}
+	return opts, nil
+}
+
+func (in *inputFlags) verifier() (*whir.Verifier, error) {
+	opts, err := in.options()
+	if err != nil {
+		return nil, err
+	}
+	return whir.NewVerifierFromFiles(*in.proof, *in.params, *in.r1cs, opts...)
+}
+
+// verifierWithoutInputs is used by the commands that only handle artifacts
+// when no WHIR proof is given.
+func (in *inputFlags) verifierWithoutInputs() (*whir.Verifier, error) {
+	opts, err := in.options()
+	if err != nil {
+		return nil, err
+	}
+	return whir.NewVerifierWithoutInputs(opts...), nil
+}
+
+func addCacheFlag(fs *flag.FlagSet) *string {
+	return fs.String("cache", "", "directory caching the constraint system and keys by circuit shape, used instead of the explicit artifact paths")
+}
+
+func requireFlags(fs *flag.FlagSet, names ...string) error {
+	for _, name := range names {
+		if fs.Lookup(name).Value.String() == "" {
+			return fmt.Errorf("missing required flag -%s", name)
+		}
+	}
+	return nil
+}
+
+func runCheck(args []string) error {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	in := addInputFlags(fs)
+	fs.Parse(args)
+	if err := requireFlags(fs, "proof", "params", "r1cs"); err != nil {
+		return err
+	}
+
+	v, err := in.verifier()
+	if err != nil {
+		return err
+	}
+	if err := v.Check(); err != nil {
+		return err
+	}
+	log.Printf("WHIR proof accepted")
+	return nil
+}
+
+func runCompile(args []string) error {
+	fs := flag.NewFlagSet("compile", flag.ExitOnError)
+	in := addInputFlags(fs)
+	ccsPath := fs.String("ccs", "", "output path for the compiled constraint system")
+	cacheDir := addCacheFlag(fs)
+	fs.Parse(args)
+	if err := requireFlags(fs, "proof", "params", "r1cs"); err != nil {
+		return err
+	}
+	if *cacheDir == "" {
+		if err := requireFlags(fs, "ccs"); err != nil {
+			return err
+		}
+	}
+
+	v, err := in.verifier()
+	if err != nil {
+		return err
+	}
+
+	if *cacheDir != "" {
+		return v.CompileCached(*cacheDir)
+	}
+	if err := v.Compile(); err != nil {
+		return err
+	}
+	log.Printf("compiled %s circuit with %d constraints", v.Backend(), v.ConstraintSystem().GetNbConstraints())
+	return v.SaveConstraintSystem(*ccsPath)
+}
+
+func runSetup(args []string) error {
+	fs := flag.NewFlagSet("setup", flag.ExitOnError)
+	in := addInputFlags(fs)
+	ccsPath := fs.String("ccs", "", "path to the compiled constraint system")
+	pkPath := fs.String("pk", "", "output path for the proving key")
+	vkPath := fs.String("vk", "", "output path for the verifying key")
+	cacheDir := addCacheFlag(fs)
+	fs.Parse(args)
+
+	if *cacheDir != "" {
+		if err := requireFlags(fs, "proof", "params", "r1cs"); err != nil {
+			return err
+		}
+		v, err := in.verifier()
+		if err != nil {
+			return err
+		}
+		return v.SetupCached(*cacheDir)
+	}
+
+	if err := requireFlags(fs, "ccs", "pk", "vk"); err != nil {
+		return err
+	}
+	v, err := in.verifierWithoutInputs()
+	if err != nil {
+		return err
+	}
+	if err := v.LoadConstraintSystem(*ccsPath); err != nil {
+		return err
+	}
+	if err := v.Setup(); err != nil {
+		return err
+	}
+	return v.SaveKeys(*pkPath, *vkPath)
+}
+
+func runProve(args []string) error {
+	fs := flag.NewFlagSet("prove", flag.ExitOnError)
+	in := addInputFlags(fs)
+	ccsPath := fs.String("ccs", "", "path to the compiled constraint system")
+	pkPath := fs.String("pk", "", "path to the proving key")
+	outPath := fs.String("wrapper-proof", "", "output path for the wrapper proof")
+	cacheDir := addCacheFlag(fs)
+	skipCheck := fs.Bool("skip-check", false, "prove without first checking the WHIR proof natively")
+	fs.Parse(args)
+	if err := requireFlags(fs, "proof", "params", "r1cs", "wrapper-proof"); err != nil {
+		return err
+	}
+	if *cacheDir == "" {
+		if err := requireFlags(fs, "ccs", "pk"); err != nil {
+			return err
+		}
+	}
+
+	v, err := in.verifier()
+	if err != nil {
+		return err
+	}
+	if !*skipCheck {
+		if err := v.Check(); err != nil {
+			return err
+		}
+	}
+
+	if *cacheDir != "" {
+		if err := v.SetupCached(*cacheDir); err != nil {
+			return err
+		}
+	} else {
+		if err := v.LoadConstraintSystem(*ccsPath); err != nil {
+			return err
+		}
+		if err := v.LoadProvingKey(*pkPath); err != nil {
+			return err
+		}
+	}
+
+	wrapperProof, err := v.Prove()
+	if err != nil {
+		return err
+	}
+	return whir.WriteProof(*outPath, wrapperProof)
+}
+
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	in := addInputFlags(fs)
+	vkPath := fs.String("vk", "", "path to the verifying key")
+	wrapperProofPath := fs.String("wrapper-proof", "", "path to the wrapper proof")
+	cacheDir := addCacheFlag(fs)
+	fs.Parse(args)
+	if err := requireFlags(fs, "proof", "params", "r1cs", "wrapper-proof"); err != nil {
+		return err
+	}
+	if *cacheDir == "" {
+		if err := requireFlags(fs, "vk"); err != nil {
+			return err
+		}
+	}
+
+	v, err := in.verifier()
+	if err != nil {
+		return err
+	}
+
+	if *cacheDir != "" {
+		err = v.LoadCachedVerifyingKey(*cacheDir)
+	} else {
+		err = v.LoadVerifyingKey(*vkPath)
+	}
+	if err != nil {
+		return err
+	}
+
+	wrapperProof, err := v.ReadProof(*wrapperProofPath)
+	if err != nil {
+		return err
+	}
+	if err := v.Verify(wrapperProof); err != nil {
+		return err
+	}
+	log.Printf("proof verified")
+	return nil
+}
+
+func runExportSolidity(args []string) error {
+	fs := flag.NewFlagSet("export-solidity", flag.ExitOnError)
+	in := addInputFlags(fs)
+	vkPath := fs.String("vk", "", "path to the verifying key")
+	cacheDir := addCacheFlag(fs)
+	solidityPath := fs.String("solidity", "", "output path for the Solidity verifier contract")
+	wrapperProofPath := fs.String("wrapper-proof", "", "path to a wrapper proof to format as calldata")
+	calldataPath := fs.String("calldata", "", "output path for the verifyProof calldata of -wrapper-proof, as JSON")
+	fs.Parse(args)
+	if err := requireFlags(fs, "solidity"); err != nil {
+		return err
+	}
+	if *cacheDir == "" {
+		if err := requireFlags(fs, "vk"); err != nil {
+			return err
+		}
+	}
+	withInputs := *cacheDir != "" || *calldataPath != ""
+	if withInputs {
+		if err := requireFlags(fs, "proof", "params", "r1cs"); err != nil {
+			return err
+		}
+	}
+	if *calldataPath != "" {
+		if err := requireFlags(fs, "wrapper-proof"); err != nil {
+			return err
+		}
+	}
+
+	var v *whir.Verifier
+	var err error
+	if withInputs {
+		v, err = in.verifier()
+	} else {
+		v, err = in.verifierWithoutInputs()
+	}
+	if err != nil {
+		return err
+	}
+	if *cacheDir != "" {
+		err = v.LoadCachedVerifyingKey(*cacheDir)
+	} else {
+		err = v.LoadVerifyingKey(*vkPath)
+	}
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(*solidityPath)
+	if err != nil {
+		return err
+	}
+	if err := v.ExportSolidity(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if *calldataPath == "" {
+		return nil
+	}
+
+	wrapperProof, err := v.ReadProof(*wrapperProofPath)
+	if err != nil {
+		return err
+	}
+	calldata, err := v.Calldata(wrapperProof)
+	if err != nil {
+		return err
+	}
+	// Decode the calldata again and verify it, so that a proof the contract
+	// would reject is caught without deploying it. PLONK calldata cannot be
+	// decoded, so the proof is verified with the rules of the contract.
+	if v.Backend() == whir.BackendPlonk {
+		err = v.Verify(wrapperProof)
+	} else {
+		err = v.CheckCalldata(calldata)
+	}
+	if err != nil {
+		return err
+	}
+	encoded, err := json.MarshalIndent(calldata, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(*calldataPath, encoded, 0o644)
+}
+
+func runConstraints(args []string) error {
+	fs := flag.NewFlagSet("constraints", flag.ExitOnError)
+	in := addInputFlags(fs)
+	fs.Parse(args)
+	if err := requireFlags(fs, "proof", "params", "r1cs"); err != nil {
+		return err
+	}
+
+	v, err := in.verifier()
+	if err != nil {
+		return err
+	}
+	for _, backend := range []whir.Backend{whir.BackendGroth16, whir.BackendPlonk} {
+		n, err := v.CountConstraints(backend)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%-8s %d constraints\n", backend, n)
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package keccakSponge
+
+import (
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/math/uints"
+	"github.com/consensys/gnark/std/permutation/keccakf"
+)
+
+// Duplex adapts Digest to the duplex hash interface of gnark-nimue, so that
+// gnark_nimue.NewByteArthur replays Keccak transcripts with it. It stands in
+// for gnark_nimue.NewKeccakArthur, whose sponge copies every squeeze from
+// State()[0] instead of the squeeze position, so that consecutive squeezes
+// within one permutation repeat the same bytes. Squeezes here continue from
+// the last squeezed byte of the rate, as they do in nimue.
+type Duplex struct {
+	d *Digest
+}
+
+func NewDuplex(api frontend.API) (*Duplex, error) {
+	d, err := NewKeccak(api)
+	if err != nil {
+		return nil, err
+	}
+	return &Duplex{d: d}, nil
+}
+
+// Initialize puts the tag in the capacity, right after the rate.
+func (s *Duplex) Initialize(iv [32]byte) {
+	s.d.state = newState()
+	for i := range iv {
+		s.d.state[(rate+i)/8][(rate+i)%8] = uints.NewU8(iv[i])
+	}
+	s.d.absorb_pos = 0
+	s.d.squeeze_pos = rate
+}
+
+func (s *Duplex) Absorb(data []uints.U8) {
+	in := make([]frontend.Variable, len(data))
+	for i := range data {
+		in[i] = data[i].Val
+	}
+	s.d.Absorb(in)
+}
+
+func (s *Duplex) Squeeze(out []uints.U8) {
+	for i, v := range s.d.Squeeze(len(out)) {
+		out[i] = uints.U8{Val: v}
+	}
+}
+
+func (s *Duplex) Ratchet() {
+	s.d.state = keccakf.Permute(s.d.uapi, s.d.state)
+	for i := range rate {
+		s.d.state[i/8][i%8] = uints.NewU8(0)
+	}
+	s.d.squeeze_pos = rate
+}
+
+func (s *Duplex) PrintState(api frontend.API) {
+	vars := make([]frontend.Variable, 0, 200)
+	for _, lane := range s.d.state {
+		for _, b := range lane {
+			vars = append(vars, b.Val)
+		}
+	}
+	api.Println(vars...)
+}
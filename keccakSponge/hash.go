@@ -0,0 +1,44 @@
+package keccakSponge
+
+import (
+	"github.com/consensys/gnark/std/math/uints"
+	"github.com/consensys/gnark/std/permutation/keccakf"
+)
+
+const rate = 136
+
+// Sum256 returns the Keccak-256 hash of in, with the original Keccak padding
+// used by Ethereum rather than the SHA-3 one. The bytes of in must already be
+// range checked.
+func Sum256(uapi *uints.BinaryField[uints.U64], in []uints.U8) []uints.U8 {
+	padded := append([]uints.U8{}, in...)
+	if padding := rate - len(in)%rate; padding == 1 {
+		padded = append(padded, uints.NewU8(0x81))
+	} else {
+		padded = append(padded, uints.NewU8(0x01))
+		for range padding - 2 {
+			padded = append(padded, uints.NewU8(0))
+		}
+		padded = append(padded, uints.NewU8(0x80))
+	}
+
+	state := newState()
+	for block := 0; block < len(padded); block += rate {
+		for i := range rate / 8 {
+			var lane uints.U64
+			copy(lane[:], padded[block+8*i:block+8*i+8])
+			if block == 0 {
+				state[i] = lane
+			} else {
+				state[i] = uapi.Xor(state[i], lane)
+			}
+		}
+		state = keccakf.Permute(uapi, state)
+	}
+
+	out := make([]uints.U8, 0, 32)
+	for i := range 4 {
+		out = append(out, state[i][:]...)
+	}
+	return out
+}
@@ -1,153 +1,65 @@
 package main
 
 import (
-	"bytes"
-	"encoding/hex"
-	"encoding/json"
 	"fmt"
 	"log"
 	"os"
 
-	gnark_nimue "github.com/reilabs/gnark-nimue"
-	go_ark_serialize "github.com/reilabs/go-ark-serialize"
+	"reilabs/whir-verifier-circuit/whir"
 )
 
-type KeccakDigest struct {
-	KeccakDigest [32]uint8
-}
-
-type Fp256 struct {
-	Limbs [4]uint64
-}
-
-type MultiPath[Digest any] struct {
-	LeafSiblingHashes      []Digest
-	AuthPathsPrefixLengths []uint64
-	AuthPathsSuffixes      [][]Digest
-	LeafIndexes            []uint64
-}
-
-type ProofElement struct {
-	A MultiPath[KeccakDigest]
-	B [][]Fp256
-}
-
-type ProofObject struct {
-	FirstRoundPaths              []ProofElement `json:"round0_merkle_paths"`
-	MerklePaths                  []ProofElement `json:"merkle_paths"`
-	StatementValuesAtRandomPoint []Fp256        `json:"statement_values_at_random_point"`
-}
-
-type Config struct {
-	LogNumConstraints    int      `json:"log_num_constraints"`
-	NRounds              int      `json:"n_rounds"`
-	NVars                int      `json:"n_vars"`
-	FoldingFactor        []int    `json:"folding_factor"`
-	OODSamples           []int    `json:"ood_samples"`
-	NumQueries           []int    `json:"num_queries"`
-	PowBits              []int    `json:"pow_bits"`
-	FinalQueries         int      `json:"final_queries"`
-	FinalPowBits         int      `json:"final_pow_bits"`
-	FinalFoldingPowBits  int      `json:"final_folding_pow_bits"`
-	DomainGenerator      string   `json:"domain_generator"`
-	Rate                 int      `json:"rate"`
-	IOPattern            string   `json:"io_pattern"`
-	Transcript           []byte   `json:"transcript"`
-	TranscriptLen        int      `json:"transcript_len"`
-	StatementEvaluations []string `json:"statement_evaluations"`
-}
-
-type Item struct {
-	Constraint int    `json:"constraint"`
-	Signal     int    `json:"signal"`
-	Value      string `json:"value"`
-}
-
-type SparseMatrix struct {
-	Rows       uint64   `json:"rows"`
-	Cols       uint64   `json:"cols"`
-	RowIndices []uint64 `json:"row_indices"`
-	ColIndices []uint64 `json:"col_indices"`
-	Values     []uint64 `json:"values"`
-}
-
-type Interner struct {
-	Values []Fp256 `json:"values"`
-}
-
-type InternerAsString struct {
-	Values string `json:"values"`
-}
-
-type R1CS struct {
-	PublicInputs uint64           `json:"public_inputs"`
-	Witnesses    uint64           `json:"witnesses"`
-	Constraints  uint64           `json:"constraints"`
-	Interner     InternerAsString `json:"interner"`
-	A            SparseMatrix     `json:"a"`
-	B            SparseMatrix     `json:"b"`
-	C            SparseMatrix     `json:"c"`
+const usage = `usage: whir-verifier-circuit <command> [flags]
+
+commands:
+  check    run the WHIR verifier natively and report the step that rejects the proof
+  compile  compile the verifier circuit and write the constraint system
+  constraints
+           compile the verifier circuit for every backend and print the constraint counts
+  setup    run the setup of the backend and write the proving and verifying keys
+  prove    prove the WHIR verifier circuit and write the wrapper proof
+  verify   verify a wrapper proof against the WHIR proof it wraps
+  export-solidity
+           write the Solidity verifier and the calldata for a wrapper proof
+
+run "whir-verifier-circuit <command> -h" for the flags of each command
+`
+
+var commands = map[string]struct {
+	stage whir.Stage
+	run   func(args []string) error
+}{
+	"check":   {whir.StageCheck, runCheck},
+	"compile": {whir.StageCompile, runCompile},
+	"setup":   {whir.StageSetup, runSetup},
+	"prove":   {whir.StageProve, runProve},
+	"verify":  {whir.StageVerify, runVerify},
+
+	"constraints":     {whir.StageCompile, runConstraints},
+	"export-solidity": {whir.StageExport, runExportSolidity},
 }
 
 func main() {
-
-	proofFile, err := os.Open("../../../new-provekit/ProveKit/prover/proof")
-	if err != nil {
-		fmt.Println(err)
-		return
-	}
-
-	var proof ProofObject
-	_, err = go_ark_serialize.CanonicalDeserializeWithMode(proofFile, &proof, false, false)
-	if err != nil {
-		fmt.Println(err)
-		return
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
 	}
 
-	configFile, err := os.ReadFile("../../../new-provekit/ProveKit/prover/params")
-
-	if err != nil {
-		fmt.Println(err)
+	switch os.Args[1] {
+	case "-h", "-help", "--help", "help":
+		fmt.Print(usage)
 		return
 	}
 
-	var config Config
-	if err := json.Unmarshal(configFile, &config); err != nil {
-		log.Fatalf("Error unmarshalling JSON: %v\n", err)
+	command, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
 	}
-	fmt.Printf("Parsed configuration:\n%+v\n", config)
 
-	io := gnark_nimue.IOPattern{}
-	err = io.Parse([]byte(config.IOPattern))
-	if err != nil {
-		fmt.Println(err)
-		return
-	}
-	fmt.Printf("io: %s\n", io.PPrint())
-
-	r1csFile, r1csErr := os.ReadFile("../../../new-provekit/ProveKit/r1cs.json")
-	if r1csErr != nil {
-		fmt.Println(err)
-		return
+	// Any failure, including a proof that does not verify, exits with a
+	// non-zero status naming the stage that failed.
+	if err := command.run(os.Args[2:]); err != nil {
+		log.Print(whir.WithStage(command.stage, err))
+		os.Exit(1)
 	}
-
-	var r1cs R1CS
-	if err := json.Unmarshal(r1csFile, &r1cs); err != nil {
-		log.Fatalf("Error unmarshalling JSON: %v\n", err)
-	}
-
-	internerBytes, err := hex.DecodeString(r1cs.Interner.Values)
-	if err != nil {
-		fmt.Println(err)
-		return
-	}
-
-	var interner Interner
-	_, err = go_ark_serialize.CanonicalDeserializeWithMode(bytes.NewReader(internerBytes), &interner, false, false)
-	if err != nil {
-		fmt.Println(err)
-		return
-	}
-
-	verify_circuit(proof, config, r1cs, interner)
 }
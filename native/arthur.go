@@ -0,0 +1,292 @@
+package native
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	gnark_nimue "github.com/reilabs/gnark-nimue"
+)
+
+// Arthur replays a Fiat-Shamir transcript outside of the circuit. It mirrors
+// the gnark-nimue Arthur, so the challenges match the ones computed in the
+// circuit bit for bit.
+type Arthur interface {
+	FillNextBytes(out []byte) error
+	FillChallengeBytes(out []byte) error
+	FillNextScalars(out []fr.Element) error
+	FillChallengeScalars(out []fr.Element) error
+	// Remaining returns the number of transcript bytes not read yet.
+	Remaining() int
+}
+
+// reader holds the transcript bytes and the IO pattern shared by both
+// kinds of Arthur.
+type reader struct {
+	transcript []byte
+	ops        gnark_nimue.OpQueue
+}
+
+func newReader(io []byte, transcript []byte) (reader, error) {
+	pattern := gnark_nimue.IOPattern{}
+	if err := pattern.Parse(io); err != nil {
+		return reader{}, err
+	}
+	return reader{transcript: transcript, ops: pattern.GetOpQueue()}, nil
+}
+
+func (r *reader) Remaining() int {
+	return len(r.transcript)
+}
+
+func (r *reader) next(n int) ([]byte, error) {
+	if len(r.transcript) < n {
+		return nil, fmt.Errorf("transcript exhausted: need %d bytes, %d left", n, len(r.transcript))
+	}
+	res := r.transcript[:n]
+	r.transcript = r.transcript[n:]
+	return res, nil
+}
+
+// fieldArthur mirrors the gnark-nimue Arthur over a field sponge.
+type fieldArthur struct {
+	reader
+	sponge *duplexSponge
+}
+
+// NewSkyscraperArthur is the native counterpart of
+// gnark_nimue.NewSkyscraperArthur.
+func NewSkyscraperArthur(io []byte, transcript []byte) (Arthur, error) {
+	return newFieldArthur(io, transcript, newDuplexSponge(2, 1, func(state []fr.Element) {
+		SkyscraperPermute((*[2]fr.Element)(state))
+	}))
+}
+
+func newFieldArthur(io []byte, transcript []byte, sponge *duplexSponge) (*fieldArthur, error) {
+	r, err := newReader(io, transcript)
+	if err != nil {
+		return nil, err
+	}
+	sponge.initialize(ioTag(io))
+	return &fieldArthur{reader: r, sponge: sponge}, nil
+}
+
+func (arthur *fieldArthur) FillNextBytes(out []byte) error {
+	bytes, err := arthur.next(len(out))
+	if err != nil {
+		return err
+	}
+	copy(out, bytes)
+	for _, b := range out {
+		if err := arthur.ops.Absorb(1); err != nil {
+			return err
+		}
+		var e fr.Element
+		e.SetUint64(uint64(b))
+		arthur.sponge.absorb([]fr.Element{e})
+	}
+	return nil
+}
+
+// challengeBytesPerScalar is the number of uniformly distributed bytes taken
+// from each squeezed BN254 scalar.
+const challengeBytesPerScalar = 15
+
+func (arthur *fieldArthur) FillChallengeBytes(out []byte) error {
+	if len(out) == 0 {
+		return nil
+	}
+	lenGood := min(len(out), challengeBytesPerScalar)
+	tmp := make([]fr.Element, 1)
+	for i := range (len(out) + lenGood - 1) / lenGood {
+		if err := arthur.FillChallengeScalars(tmp); err != nil {
+			return err
+		}
+		le := LittleEndianBytes(tmp[0])
+		for k := range lenGood {
+			o := i*lenGood + k
+			if o >= len(out) {
+				break
+			}
+			out[o] = le[k]
+		}
+	}
+	return nil
+}
+
+func (arthur *fieldArthur) FillNextScalars(out []fr.Element) error {
+	for i := range out {
+		bytes, err := arthur.next(fr.Bytes)
+		if err != nil {
+			return err
+		}
+		out[i] = FromLittleEndian(bytes)
+	}
+	if err := arthur.ops.Absorb(uint64(len(out))); err != nil {
+		return err
+	}
+	arthur.sponge.absorb(out)
+	return nil
+}
+
+func (arthur *fieldArthur) FillChallengeScalars(out []fr.Element) error {
+	if err := arthur.ops.Squeeze(uint64(len(out))); err != nil {
+		return err
+	}
+	arthur.sponge.squeeze(out)
+	return nil
+}
+
+// byteArthur mirrors the gnark-nimue Arthur over a byte sponge.
+type byteArthur struct {
+	reader
+	sponge *keccakDuplex
+}
+
+// NewKeccakArthur is the native counterpart of gnark_nimue.NewByteArthur
+// over a keccakSponge.Duplex.
+func NewKeccakArthur(io []byte, transcript []byte) (Arthur, error) {
+	r, err := newReader(io, transcript)
+	if err != nil {
+		return nil, err
+	}
+	sponge := &keccakDuplex{}
+	sponge.initialize(ioTag(io))
+	return &byteArthur{reader: r, sponge: sponge}, nil
+}
+
+func (arthur *byteArthur) FillNextBytes(out []byte) error {
+	bytes, err := arthur.next(len(out))
+	if err != nil {
+		return err
+	}
+	copy(out, bytes)
+	if err := arthur.ops.Absorb(uint64(len(out))); err != nil {
+		return err
+	}
+	arthur.sponge.absorb(out)
+	return nil
+}
+
+func (arthur *byteArthur) FillChallengeBytes(out []byte) error {
+	if err := arthur.ops.Squeeze(uint64(len(out))); err != nil {
+		return err
+	}
+	arthur.sponge.squeeze(out)
+	return nil
+}
+
+func (arthur *byteArthur) FillNextScalars(out []fr.Element) error {
+	bytes := make([]byte, fr.Bytes)
+	for i := range out {
+		if err := arthur.FillNextBytes(bytes); err != nil {
+			return err
+		}
+		out[i] = FromLittleEndian(bytes)
+	}
+	return nil
+}
+
+// byteChallengeBytesPerScalar is the number of bytes squeezed for each
+// challenge scalar, 128 bits more than the field so the bias is negligible.
+const byteChallengeBytesPerScalar = (fr.Bits + 128) / 8
+
+func (arthur *byteArthur) FillChallengeScalars(out []fr.Element) error {
+	bytes := make([]byte, byteChallengeBytesPerScalar)
+	for i := range out {
+		if err := arthur.FillChallengeBytes(bytes); err != nil {
+			return err
+		}
+		out[i] = FromBigEndian(bytes)
+	}
+	return nil
+}
+
+// duplexSponge mirrors gnark-nimue's DuplexSponge over field elements,
+// including its overwrite mode absorption. Squeezes continue from the last
+// squeezed element, which only differs from gnark-nimue above a rate of 1.
+type duplexSponge struct {
+	state      []fr.Element
+	rate       int
+	permute    func(state []fr.Element)
+	absorbPos  int
+	squeezePos int
+}
+
+func newDuplexSponge(width int, rate int, permute func(state []fr.Element)) *duplexSponge {
+	return &duplexSponge{
+		state:   make([]fr.Element, width),
+		rate:    rate,
+		permute: permute,
+	}
+}
+
+// initialize puts the tag, read as a little-endian integer, in the first
+// capacity element.
+func (s *duplexSponge) initialize(iv [32]byte) {
+	for i := range s.state {
+		s.state[i].SetZero()
+	}
+	s.state[s.rate] = FromLittleEndian(iv[:])
+	s.absorbPos = 0
+	s.squeezePos = s.rate
+}
+
+func (s *duplexSponge) absorb(input []fr.Element) {
+	for len(input) > 0 {
+		if s.absorbPos == s.rate {
+			s.permute(s.state)
+			s.absorbPos = 0
+		} else {
+			chunkLen := min(len(input), s.rate-s.absorbPos)
+			copy(s.state[s.absorbPos:], input[:chunkLen])
+			s.absorbPos += chunkLen
+			input = input[chunkLen:]
+		}
+	}
+	s.squeezePos = s.rate
+}
+
+func (s *duplexSponge) squeeze(output []fr.Element) {
+	for len(output) > 0 {
+		if s.squeezePos == s.rate {
+			s.squeezePos = 0
+			s.absorbPos = 0
+			s.permute(s.state)
+		}
+		chunkLen := min(len(output), s.rate-s.squeezePos)
+		copy(output[:chunkLen], s.state[s.squeezePos:])
+		s.squeezePos += chunkLen
+		output = output[chunkLen:]
+	}
+}
+
+// FromLittleEndian reads bytes as a little-endian integer reduced modulo the
+// field order.
+func FromLittleEndian(bytes []byte) fr.Element {
+	be := make([]byte, len(bytes))
+	for i := range bytes {
+		be[len(bytes)-1-i] = bytes[i]
+	}
+	var e fr.Element
+	e.SetBigInt(new(big.Int).SetBytes(be))
+	return e
+}
+
+// FromBigEndian reads bytes as a big-endian integer reduced modulo the field
+// order.
+func FromBigEndian(bytes []byte) fr.Element {
+	var e fr.Element
+	e.SetBigInt(new(big.Int).SetBytes(bytes))
+	return e
+}
+
+// LittleEndianBytes returns the canonical little-endian encoding of e.
+func LittleEndianBytes(e fr.Element) [fr.Bytes]byte {
+	be := e.Bytes()
+	le := [fr.Bytes]byte{}
+	for i := range be {
+		le[fr.Bytes-1-i] = be[i]
+	}
+	return le
+}
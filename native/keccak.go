@@ -0,0 +1,159 @@
+package native
+
+import "math/bits"
+
+var keccakRoundConstants = [24]uint64{
+	0x0000000000000001, 0x0000000000008082, 0x800000000000808a, 0x8000000080008000,
+	0x000000000000808b, 0x0000000080000001, 0x8000000080008081, 0x8000000000008009,
+	0x000000000000008a, 0x0000000000000088, 0x0000000080008009, 0x000000008000000a,
+	0x000000008000808b, 0x800000000000008b, 0x8000000000008089, 0x8000000000008003,
+	0x8000000000008002, 0x8000000000000080, 0x000000000000800a, 0x800000008000000a,
+	0x8000000080008081, 0x8000000000008080, 0x0000000080000001, 0x8000000080008008,
+}
+
+// keccakRotations holds the rho offsets, indexed by x + 5y.
+var keccakRotations = [25]int{
+	0, 1, 62, 28, 27,
+	36, 44, 6, 55, 20,
+	3, 10, 43, 25, 39,
+	41, 45, 15, 21, 8,
+	18, 2, 61, 56, 14,
+}
+
+// KeccakF1600 applies the Keccak-f[1600] permutation to the state, whose
+// lanes are indexed by x + 5y.
+func KeccakF1600(a *[25]uint64) {
+	for round := range 24 {
+		var c [5]uint64
+		for x := range 5 {
+			c[x] = a[x] ^ a[x+5] ^ a[x+10] ^ a[x+15] ^ a[x+20]
+		}
+		for x := range 5 {
+			d := c[(x+4)%5] ^ bits.RotateLeft64(c[(x+1)%5], 1)
+			for y := 0; y < 25; y += 5 {
+				a[y+x] ^= d
+			}
+		}
+
+		var b [25]uint64
+		for x := range 5 {
+			for y := range 5 {
+				b[y+5*((2*x+3*y)%5)] = bits.RotateLeft64(a[x+5*y], keccakRotations[x+5*y])
+			}
+		}
+
+		for y := 0; y < 25; y += 5 {
+			for x := range 5 {
+				a[y+x] = b[y+x] ^ (^b[y+(x+1)%5] & b[y+(x+2)%5])
+			}
+		}
+
+		a[0] ^= keccakRoundConstants[round]
+	}
+}
+
+// KeccakFBytes applies Keccak-f[1600] to a byte state, reading the lanes as
+// little-endian words.
+func KeccakFBytes(state *[200]byte) {
+	lanes := [25]uint64{}
+	for i := range 25 {
+		for j := range 8 {
+			lanes[i] |= uint64(state[i*8+j]) << (8 * j)
+		}
+	}
+	KeccakF1600(&lanes)
+	for i := range 25 {
+		for j := range 8 {
+			state[i*8+j] = byte(lanes[i] >> (8 * j))
+		}
+	}
+}
+
+// ioTag derives the sponge initialisation vector from the IO pattern the same
+// way gnark-nimue does: the pattern is absorbed into the Keccak rate without
+// padding and the first 32 bytes of the permuted state are the tag.
+func ioTag(io []byte) [32]byte {
+	const rate = 136
+	state := [200]byte{}
+	absorbPos := 0
+	for len(io) > 0 {
+		if absorbPos == rate {
+			KeccakFBytes(&state)
+			absorbPos = 0
+		} else {
+			chunkLen := min(len(io), rate-absorbPos)
+			copy(state[absorbPos:], io[:chunkLen])
+			absorbPos += chunkLen
+			io = io[chunkLen:]
+		}
+	}
+	KeccakFBytes(&state)
+	tag := [32]byte{}
+	copy(tag[:], state[:32])
+	return tag
+}
+
+// keccakDuplex mirrors keccakSponge.Duplex: the rate is overwritten on
+// absorption and squeezes continue from the last squeezed byte.
+type keccakDuplex struct {
+	state      [200]byte
+	absorbPos  int
+	squeezePos int
+}
+
+func (s *keccakDuplex) initialize(iv [32]byte) {
+	const rate = 136
+	s.state = [200]byte{}
+	copy(s.state[rate:], iv[:])
+	s.absorbPos = 0
+	s.squeezePos = rate
+}
+
+func (s *keccakDuplex) absorb(input []byte) {
+	const rate = 136
+	for _, b := range input {
+		if s.absorbPos == rate {
+			KeccakFBytes(&s.state)
+			s.absorbPos = 0
+		}
+		s.state[s.absorbPos] = b
+		s.absorbPos++
+	}
+	s.squeezePos = rate
+}
+
+func (s *keccakDuplex) squeeze(output []byte) {
+	const rate = 136
+	for i := range output {
+		if s.squeezePos == rate {
+			s.squeezePos = 0
+			s.absorbPos = 0
+			KeccakFBytes(&s.state)
+		}
+		output[i] = s.state[s.squeezePos]
+		s.squeezePos++
+	}
+}
+
+// Keccak256 is the Ethereum Keccak-256 hash, the original Keccak padding with
+// a 136 byte rate.
+func Keccak256(data []byte) [32]byte {
+	const rate = 136
+	state := [200]byte{}
+	for len(data) >= rate {
+		for i := range rate {
+			state[i] ^= data[i]
+		}
+		KeccakFBytes(&state)
+		data = data[rate:]
+	}
+	for i := range data {
+		state[i] ^= data[i]
+	}
+	state[len(data)] ^= 0x01
+	state[rate-1] ^= 0x80
+	KeccakFBytes(&state)
+	digest := [32]byte{}
+	copy(digest[:], state[:32])
+	return digest
+}
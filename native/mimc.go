@@ -0,0 +1,28 @@
+package native
+
+import (
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr/mimc"
+)
+
+// MiMCHash is the native counterpart of gnark's std MiMC hash of the given
+// elements, from a reset state.
+func MiMCHash(elements ...fr.Element) fr.Element {
+	h := mimc.NewMiMC()
+	for _, e := range elements {
+		bytes := e.Bytes()
+		h.Write(bytes[:])
+	}
+	var res fr.Element
+	res.SetBytes(h.Sum(nil))
+	return res
+}
+
+// NewMiMCArthur is the native counterpart of the transcript of the MiMC
+// duplex sponge of the circuit, which permutes the state (r, c) into
+// (MiMC(r, c), MiMC(c, r)).
+func NewMiMCArthur(io []byte, transcript []byte) (Arthur, error) {
+	return newFieldArthur(io, transcript, newDuplexSponge(2, 1, func(state []fr.Element) {
+		state[0], state[1] = MiMCHash(state[0], state[1]), MiMCHash(state[1], state[0])
+	}))
+}
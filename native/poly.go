@@ -0,0 +1,135 @@
+package native
+
+import (
+	"math/big"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+)
+
+// The functions below are the native counterparts of the gadgets of the same
+// name in utilities.
+
+func MultivarPoly(coefs []fr.Element, vars []fr.Element) fr.Element {
+	if len(vars) == 0 {
+		return coefs[0]
+	}
+	degZero := MultivarPoly(coefs[:len(coefs)/2], vars[:len(vars)-1])
+	degOne := MultivarPoly(coefs[len(coefs)/2:], vars[:len(vars)-1])
+	degOne.Mul(&degOne, &vars[len(vars)-1])
+	degZero.Add(&degZero, &degOne)
+	return degZero
+}
+
+func UnivarPoly(coefficients []fr.Element, point fr.Element) fr.Element {
+	var ans fr.Element
+	for i := range coefficients {
+		ans.Mul(&ans, &point)
+		ans.Add(&ans, &coefficients[len(coefficients)-1-i])
+	}
+	return ans
+}
+
+func EqPolyOutside(coords []fr.Element, point []fr.Element) fr.Element {
+	var one fr.Element
+	one.SetOne()
+	acc := one
+	for i := range coords {
+		var a, b, c fr.Element
+		a.Mul(&coords[i], &point[i])
+		b.Sub(&one, &coords[i])
+		c.Sub(&one, &point[i])
+		b.Mul(&b, &c)
+		a.Add(&a, &b)
+		acc.Mul(&acc, &a)
+	}
+	return acc
+}
+
+func Exponent(x fr.Element, y uint64) fr.Element {
+	var res fr.Element
+	res.Exp(x, new(big.Int).SetUint64(y))
+	return res
+}
+
+func ExpandRandomness(base fr.Element, length int) []fr.Element {
+	res := make([]fr.Element, length)
+	var acc fr.Element
+	acc.SetOne()
+	for i := range length {
+		res[i] = acc
+		acc.Mul(&acc, &base)
+	}
+	return res
+}
+
+func ExpandFromUnivariate(base fr.Element, length int) []fr.Element {
+	res := make([]fr.Element, length)
+	acc := base
+	for i := range length {
+		res[length-1-i] = acc
+		acc.Square(&acc)
+	}
+	return res
+}
+
+func DotProduct(a []fr.Element, b []fr.Element) fr.Element {
+	var acc fr.Element
+	for i := range a {
+		var term fr.Element
+		term.Mul(&a[i], &b[i])
+		acc.Add(&acc, &term)
+	}
+	return acc
+}
+
+// EqOverBooleanHypercube is the native counterpart of
+// calculateEQOverBooleanHypercube.
+func EqOverBooleanHypercube(r []fr.Element) []fr.Element {
+	ans := make([]fr.Element, 1)
+	ans[0].SetOne()
+	for i := len(r) - 1; i >= 0; i-- {
+		var oneMinusX fr.Element
+		oneMinusX.SetOne()
+		oneMinusX.Sub(&oneMinusX, &r[i])
+		next := make([]fr.Element, 2*len(ans))
+		for j := range ans {
+			next[j].Mul(&ans[j], &oneMinusX)
+			next[len(ans)+j].Mul(&ans[j], &r[i])
+		}
+		ans = next
+	}
+	return ans
+}
+
+func Reverse(s []fr.Element) []fr.Element {
+	res := make([]fr.Element, len(s))
+	for i := range s {
+		res[len(s)-1-i] = s[i]
+	}
+	return res
+}
+
+// EvaluateFromEvaluationList mirrors utilities.EvaluateFromEvaluationList.
+func EvaluateFromEvaluationList(evaluations []fr.Element, point fr.Element) fr.Element {
+	var ans fr.Element
+	for i := range evaluations {
+		var numerator, denominator, term fr.Element
+		numerator.SetOne()
+		denominator.SetOne()
+		for j := range evaluations {
+			if j == i {
+				continue
+			}
+			var node, diff fr.Element
+			node.SetUint64(uint64(j))
+			diff.Sub(&point, &node)
+			numerator.Mul(&numerator, &diff)
+			diff.SetInt64(int64(i - j))
+			denominator.Mul(&denominator, &diff)
+		}
+		denominator.Inverse(&denominator)
+		term.Mul(&evaluations[i], &numerator).Mul(&term, &denominator)
+		ans.Add(&ans, &term)
+	}
+	return ans
+}
@@ -0,0 +1,97 @@
+package native
+
+import (
+	"reilabs/whir-verifier-circuit/poseidon2"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+)
+
+var poseidon2ExternalRoundConstants [poseidon2.FullRounds][poseidon2.Width]fr.Element
+var poseidon2InternalRoundConstants [poseidon2.PartialRounds]fr.Element
+
+func init() {
+	for i := range poseidon2.ExternalRoundConstants {
+		for j := range poseidon2.ExternalRoundConstants[i] {
+			poseidon2ExternalRoundConstants[i][j] = elementFromString(poseidon2.ExternalRoundConstants[i][j])
+		}
+	}
+	for i := range poseidon2.InternalRoundConstants {
+		poseidon2InternalRoundConstants[i] = elementFromString(poseidon2.InternalRoundConstants[i])
+	}
+}
+
+func poseidon2Sbox(x *fr.Element) {
+	var x2 fr.Element
+	x2.Square(x)
+	x2.Square(&x2)
+	x.Mul(x, &x2)
+}
+
+func poseidon2MatMulExternal(state *[poseidon2.Width]fr.Element) {
+	var sum fr.Element
+	sum.Add(&state[0], &state[1]).Add(&sum, &state[2])
+	for j := range state {
+		state[j].Add(&state[j], &sum)
+	}
+}
+
+func poseidon2MatMulInternal(state *[poseidon2.Width]fr.Element) {
+	var sum fr.Element
+	sum.Add(&state[0], &state[1]).Add(&sum, &state[2])
+	state[0].Add(&state[0], &sum)
+	state[1].Add(&state[1], &sum)
+	state[2].Double(&state[2]).Add(&state[2], &sum)
+}
+
+func poseidon2FullRound(state *[poseidon2.Width]fr.Element, round int) {
+	for j := range state {
+		state[j].Add(&state[j], &poseidon2ExternalRoundConstants[round][j])
+		poseidon2Sbox(&state[j])
+	}
+	poseidon2MatMulExternal(state)
+}
+
+// Poseidon2Permute is the native counterpart of poseidon2.Poseidon2.Permute.
+func Poseidon2Permute(state *[poseidon2.Width]fr.Element) {
+	poseidon2MatMulExternal(state)
+	for i := range poseidon2.FullRounds / 2 {
+		poseidon2FullRound(state, i)
+	}
+	for i := range poseidon2.PartialRounds {
+		state[0].Add(&state[0], &poseidon2InternalRoundConstants[i])
+		poseidon2Sbox(&state[0])
+		poseidon2MatMulInternal(state)
+	}
+	for i := poseidon2.FullRounds / 2; i < poseidon2.FullRounds; i++ {
+		poseidon2FullRound(state, i)
+	}
+}
+
+// Poseidon2Compress is the native counterpart of
+// poseidon2.Poseidon2.Compress.
+func Poseidon2Compress(left, right fr.Element) fr.Element {
+	state := [poseidon2.Width]fr.Element{left, right}
+	Poseidon2Permute(&state)
+	return state[0]
+}
+
+// Poseidon2HashLeaf is the native counterpart of
+// poseidon2.Poseidon2.HashLeaf.
+func Poseidon2HashLeaf(elements ...fr.Element) fr.Element {
+	state := [poseidon2.Width]fr.Element{}
+	for len(elements) > 0 {
+		chunk := elements[:min(len(elements), poseidon2.Rate)]
+		copy(state[:], chunk)
+		Poseidon2Permute(&state)
+		elements = elements[len(chunk):]
+	}
+	return state[0]
+}
+
+// NewPoseidon2Arthur is the native counterpart of the transcript of a
+// poseidon2.Duplex.
+func NewPoseidon2Arthur(io []byte, transcript []byte) (Arthur, error) {
+	return newFieldArthur(io, transcript, newDuplexSponge(poseidon2.Width, poseidon2.Rate, func(state []fr.Element) {
+		Poseidon2Permute((*[poseidon2.Width]fr.Element)(state))
+	}))
+}
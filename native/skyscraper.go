@@ -0,0 +1,91 @@
+package native
+
+import (
+	"math/bits"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+)
+
+var skyscraperRoundConstants = [8]fr.Element{
+	elementFromString("17829420340877239108687448009732280677191990375576158938221412342251481978692"),
+	elementFromString("5852100059362614845584985098022261541909346143980691326489891671321030921585"),
+	elementFromString("17048088173265532689680903955395019356591870902241717143279822196003888806966"),
+	elementFromString("71577923540621522166602308362662170286605786204339342029375621502658138039"),
+	elementFromString("1630526119629192105940988602003704216811347521589219909349181656165466494167"),
+	elementFromString("7807402158218786806372091124904574238561123446618083586948014838053032654983"),
+	elementFromString("13329560971460034925899588938593812685746818331549554971040309989641523590611"),
+	elementFromString("16971509144034029782226530622087626979814683266929655790026304723118124142299"),
+}
+
+var skyscraperSigma = elementFromString("9915499612839321149637521777990102151350674507940716049588462388200839649614")
+
+func elementFromString(s string) fr.Element {
+	var e fr.Element
+	if _, err := e.SetString(s); err != nil {
+		panic(err)
+	}
+	return e
+}
+
+func skyscraperSboxByte(b byte) byte {
+	x := bits.RotateLeft8(^b, 1)
+	y := bits.RotateLeft8(b, 2)
+	z := bits.RotateLeft8(b, 3)
+	return bits.RotateLeft8(b^(x&y&z), 1)
+}
+
+func skyscraperSquare(v fr.Element) fr.Element {
+	var res fr.Element
+	res.Square(&v)
+	res.Mul(&res, &skyscraperSigma)
+	return res
+}
+
+func skyscraperBar(v fr.Element) fr.Element {
+	bytes := v.Bytes()
+	swapped := [32]byte{}
+	copy(swapped[:16], bytes[16:])
+	copy(swapped[16:], bytes[:16])
+	for i := range swapped {
+		swapped[i] = skyscraperSboxByte(swapped[i])
+	}
+	var res fr.Element
+	res.SetBytes(swapped[:])
+	return res
+}
+
+// SkyscraperPermute is the native counterpart of the in-circuit
+// gnark-skyscraper permutation.
+func SkyscraperPermute(state *[2]fr.Element) {
+	round := func(l, r fr.Element, f func(fr.Element) fr.Element, rc *fr.Element) (fr.Element, fr.Element) {
+		fl := f(l)
+		var next fr.Element
+		next.Add(&r, &fl)
+		if rc != nil {
+			next.Add(&next, rc)
+		}
+		return next, l
+	}
+
+	l, r := state[0], state[1]
+	l, r = round(l, r, skyscraperSquare, nil)
+	l, r = round(l, r, skyscraperSquare, &skyscraperRoundConstants[0])
+	l, r = round(l, r, skyscraperBar, &skyscraperRoundConstants[1])
+	l, r = round(l, r, skyscraperBar, &skyscraperRoundConstants[2])
+	l, r = round(l, r, skyscraperSquare, &skyscraperRoundConstants[3])
+	l, r = round(l, r, skyscraperSquare, &skyscraperRoundConstants[4])
+	l, r = round(l, r, skyscraperBar, &skyscraperRoundConstants[5])
+	l, r = round(l, r, skyscraperBar, &skyscraperRoundConstants[6])
+	l, r = round(l, r, skyscraperSquare, &skyscraperRoundConstants[7])
+	l, r = round(l, r, skyscraperSquare, nil)
+	state[0], state[1] = l, r
+}
+
+// SkyscraperCompress is the native counterpart of Skyscraper.Compress.
+func SkyscraperCompress(l, r fr.Element) fr.Element {
+	state := [2]fr.Element{l, r}
+	SkyscraperPermute(&state)
+	var res fr.Element
+	res.Add(&l, &state[0])
+	return res
+}
@@ -0,0 +1,73 @@
+package poseidon2
+
+import (
+	"fmt"
+	"math/big"
+	"slices"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// Duplex is a duplex sponge over the Poseidon2 permutation with a rate of 2,
+// implementing the duplex hash interface of gnark-nimue. Squeezes continue
+// from the last squeezed element of the rate.
+type Duplex struct {
+	p          *Poseidon2
+	state      [Width]frontend.Variable
+	absorbPos  int
+	squeezePos int
+}
+
+func NewDuplex(api frontend.API) *Duplex {
+	return &Duplex{p: NewPoseidon2(api)}
+}
+
+// Initialize puts the tag, read as a little-endian integer, in the capacity.
+func (s *Duplex) Initialize(iv [32]byte) {
+	slices.Reverse(iv[:])
+	s.state = [Width]frontend.Variable{0, 0, new(big.Int).SetBytes(iv[:])}
+	s.absorbPos = 0
+	s.squeezePos = Rate
+}
+
+func (s *Duplex) Absorb(input []frontend.Variable) {
+	for len(input) > 0 {
+		if s.absorbPos == Rate {
+			s.p.Permute(&s.state)
+			s.absorbPos = 0
+		} else {
+			chunkLen := min(len(input), Rate-s.absorbPos)
+			copy(s.state[s.absorbPos:], input[:chunkLen])
+			s.absorbPos += chunkLen
+			input = input[chunkLen:]
+		}
+	}
+	s.squeezePos = Rate
+}
+
+func (s *Duplex) Squeeze(output []frontend.Variable) {
+	for len(output) > 0 {
+		if s.squeezePos == Rate {
+			s.squeezePos = 0
+			s.absorbPos = 0
+			s.p.Permute(&s.state)
+		}
+		chunkLen := min(len(output), Rate-s.squeezePos)
+		copy(output[:chunkLen], s.state[s.squeezePos:])
+		s.squeezePos += chunkLen
+		output = output[chunkLen:]
+	}
+}
+
+func (s *Duplex) Ratchet() {
+	s.p.Permute(&s.state)
+	for i := range Rate {
+		s.state[i] = 0
+	}
+	s.squeezePos = Rate
+}
+
+func (s *Duplex) PrintState(api frontend.API) {
+	api.Println(fmt.Sprintf("absorbPos %d squeezePos %d", s.absorbPos, s.squeezePos))
+	api.Println(s.state[:]...)
+}
@@ -0,0 +1,110 @@
+package poseidon2
+
+import (
+	"math/big"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+const (
+	Width         = 3
+	Rate          = 2
+	FullRounds    = 8
+	PartialRounds = 56
+)
+
+var externalRoundConstants [FullRounds][Width]*big.Int
+var internalRoundConstants [PartialRounds]*big.Int
+
+func init() {
+	for i := range ExternalRoundConstants {
+		for j := range ExternalRoundConstants[i] {
+			externalRoundConstants[i][j] = parseConstant(ExternalRoundConstants[i][j])
+		}
+	}
+	for i := range InternalRoundConstants {
+		internalRoundConstants[i] = parseConstant(InternalRoundConstants[i])
+	}
+}
+
+func parseConstant(s string) *big.Int {
+	c, ok := new(big.Int).SetString(s, 0)
+	if !ok {
+		panic("invalid poseidon2 round constant " + s)
+	}
+	return c
+}
+
+// Poseidon2 is the Poseidon2 permutation over BN254 with a width of 3 and
+// the x^5 S-box.
+type Poseidon2 struct {
+	api frontend.API
+}
+
+func NewPoseidon2(api frontend.API) *Poseidon2 {
+	return &Poseidon2{api: api}
+}
+
+func (p *Poseidon2) Permute(state *[Width]frontend.Variable) {
+	p.matMulExternal(state)
+	for i := range FullRounds / 2 {
+		p.fullRound(state, i)
+	}
+	for i := range PartialRounds {
+		state[0] = p.sbox(p.api.Add(state[0], internalRoundConstants[i]))
+		p.matMulInternal(state)
+	}
+	for i := FullRounds / 2; i < FullRounds; i++ {
+		p.fullRound(state, i)
+	}
+}
+
+// Compress is the truncated permutation of left and right, the first element
+// of the permutation of [left, right, 0].
+func (p *Poseidon2) Compress(left, right frontend.Variable) frontend.Variable {
+	state := [Width]frontend.Variable{left, right, 0}
+	p.Permute(&state)
+	return state[0]
+}
+
+// HashLeaf absorbs the elements into a zero state, Rate at a time and
+// without padding, and returns the first element of the state.
+func (p *Poseidon2) HashLeaf(elements ...frontend.Variable) frontend.Variable {
+	state := [Width]frontend.Variable{0, 0, 0}
+	for len(elements) > 0 {
+		chunk := elements[:min(len(elements), Rate)]
+		copy(state[:], chunk)
+		p.Permute(&state)
+		elements = elements[len(chunk):]
+	}
+	return state[0]
+}
+
+func (p *Poseidon2) fullRound(state *[Width]frontend.Variable, round int) {
+	for j := range state {
+		state[j] = p.sbox(p.api.Add(state[j], externalRoundConstants[round][j]))
+	}
+	p.matMulExternal(state)
+}
+
+func (p *Poseidon2) sbox(x frontend.Variable) frontend.Variable {
+	x2 := p.api.Mul(x, x)
+	x4 := p.api.Mul(x2, x2)
+	return p.api.Mul(x4, x)
+}
+
+// matMulExternal multiplies the state by circ(2, 1, 1).
+func (p *Poseidon2) matMulExternal(state *[Width]frontend.Variable) {
+	sum := p.api.Add(state[0], state[1], state[2])
+	for j := range state {
+		state[j] = p.api.Add(state[j], sum)
+	}
+}
+
+// matMulInternal multiplies the state by [[2, 1, 1], [1, 2, 1], [1, 1, 3]].
+func (p *Poseidon2) matMulInternal(state *[Width]frontend.Variable) {
+	sum := p.api.Add(state[0], state[1], state[2])
+	state[0] = p.api.Add(state[0], sum)
+	state[1] = p.api.Add(state[1], sum)
+	state[2] = p.api.Add(state[2], state[2], sum)
+}
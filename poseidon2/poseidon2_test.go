@@ -0,0 +1,194 @@
+package poseidon2_test
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"os"
+	"reilabs/whir-verifier-circuit/native"
+	"reilabs/whir-verifier-circuit/poseidon2"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+)
+
+// vectors are computed with an independent implementation of Poseidon2
+// whose permutation matches the reference implementation of HorizenLabs.
+type vectors struct {
+	Permutation []struct {
+		Input  []string
+		Output []string
+	}
+	Compress []struct {
+		Left   string
+		Right  string
+		Output string
+	}
+	HashLeaf []struct {
+		Input  []string
+		Output string
+	} `json:"hash_leaf"`
+	Duplex []struct {
+		IV      string
+		Absorb  []string
+		Squeeze []string
+	}
+}
+
+func readVectors(t *testing.T) vectors {
+	data, err := os.ReadFile("testdata/vectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v vectors
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func element(t *testing.T, s string) fr.Element {
+	var e fr.Element
+	if _, err := e.SetString(s); err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func elements(t *testing.T, s []string) []fr.Element {
+	res := make([]fr.Element, len(s))
+	for i := range s {
+		res[i] = element(t, s[i])
+	}
+	return res
+}
+
+func variables(t *testing.T, s []string) []frontend.Variable {
+	res := make([]frontend.Variable, len(s))
+	for i := range s {
+		e := element(t, s[i])
+		res[i] = e.BigInt(new(big.Int))
+	}
+	return res
+}
+
+type permutationCircuit struct {
+	Input  [poseidon2.Width]frontend.Variable
+	Output [poseidon2.Width]frontend.Variable
+}
+
+func (c *permutationCircuit) Define(api frontend.API) error {
+	state := c.Input
+	poseidon2.NewPoseidon2(api).Permute(&state)
+	for i := range state {
+		api.AssertIsEqual(state[i], c.Output[i])
+	}
+	return nil
+}
+
+func TestPermutation(t *testing.T) {
+	for _, v := range readVectors(t).Permutation {
+		state := [poseidon2.Width]fr.Element(elements(t, v.Input))
+		native.Poseidon2Permute(&state)
+		if want := elements(t, v.Output); [poseidon2.Width]fr.Element(want) != state {
+			t.Errorf("native permutation of %v is %v, expected %v", v.Input, state, want)
+		}
+
+		assignment := &permutationCircuit{
+			Input:  [poseidon2.Width]frontend.Variable(variables(t, v.Input)),
+			Output: [poseidon2.Width]frontend.Variable(variables(t, v.Output)),
+		}
+		if err := test.IsSolved(&permutationCircuit{}, assignment, ecc.BN254.ScalarField()); err != nil {
+			t.Errorf("permutation of %v: %v", v.Input, err)
+		}
+	}
+}
+
+type compressCircuit struct {
+	Left, Right, Output frontend.Variable
+}
+
+func (c *compressCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(poseidon2.NewPoseidon2(api).Compress(c.Left, c.Right), c.Output)
+	return nil
+}
+
+func TestCompress(t *testing.T) {
+	for _, v := range readVectors(t).Compress {
+		res := native.Poseidon2Compress(element(t, v.Left), element(t, v.Right))
+		if want := element(t, v.Output); res != want {
+			t.Errorf("native compression of %s and %s is %s, expected %s", v.Left, v.Right, res.String(), want.String())
+		}
+
+		in := variables(t, []string{v.Left, v.Right, v.Output})
+		assignment := &compressCircuit{Left: in[0], Right: in[1], Output: in[2]}
+		if err := test.IsSolved(&compressCircuit{}, assignment, ecc.BN254.ScalarField()); err != nil {
+			t.Errorf("compression of %s and %s: %v", v.Left, v.Right, err)
+		}
+	}
+}
+
+type hashLeafCircuit struct {
+	Input  []frontend.Variable
+	Output frontend.Variable
+}
+
+func (c *hashLeafCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(poseidon2.NewPoseidon2(api).HashLeaf(c.Input...), c.Output)
+	return nil
+}
+
+func TestHashLeaf(t *testing.T) {
+	for _, v := range readVectors(t).HashLeaf {
+		res := native.Poseidon2HashLeaf(elements(t, v.Input)...)
+		if want := element(t, v.Output); res != want {
+			t.Errorf("native hash of leaf %v is %s, expected %s", v.Input, res.String(), want.String())
+		}
+
+		circuit := &hashLeafCircuit{Input: make([]frontend.Variable, len(v.Input))}
+		assignment := &hashLeafCircuit{Input: variables(t, v.Input), Output: variables(t, []string{v.Output})[0]}
+		if err := test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()); err != nil {
+			t.Errorf("hash of leaf %v: %v", v.Input, err)
+		}
+	}
+}
+
+type duplexCircuit struct {
+	iv      [32]byte
+	Absorb  []frontend.Variable
+	Squeeze []frontend.Variable
+}
+
+func (c *duplexCircuit) Define(api frontend.API) error {
+	sponge := poseidon2.NewDuplex(api)
+	sponge.Initialize(c.iv)
+	sponge.Absorb(c.Absorb)
+	out := make([]frontend.Variable, len(c.Squeeze))
+	sponge.Squeeze(out)
+	for i := range out {
+		api.AssertIsEqual(out[i], c.Squeeze[i])
+	}
+	return nil
+}
+
+func TestDuplex(t *testing.T) {
+	for _, v := range readVectors(t).Duplex {
+		bytes, err := hex.DecodeString(v.IV)
+		if err != nil {
+			t.Fatal(err)
+		}
+		iv := [32]byte(bytes)
+		circuit := &duplexCircuit{
+			iv:      iv,
+			Absorb:  make([]frontend.Variable, len(v.Absorb)),
+			Squeeze: make([]frontend.Variable, len(v.Squeeze)),
+		}
+		assignment := &duplexCircuit{iv: iv, Absorb: variables(t, v.Absorb), Squeeze: variables(t, v.Squeeze)}
+		if err := test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()); err != nil {
+			t.Errorf("duplex absorbing %v: %v", v.Absorb, err)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package utilities
+
+import (
+	"math/big"
+
+	"github.com/consensys/gnark/frontend"
+	gnark_nimue "github.com/reilabs/gnark-nimue"
+)
+
+// SumcheckEncoding is the form in which the prover sends the round
+// polynomials of a sumcheck.
+type SumcheckEncoding int
+
+const (
+	// SumcheckEvaluations sends the evaluations at 0, 1, ..., degree.
+	SumcheckEvaluations SumcheckEncoding = iota
+	// SumcheckCoefficients sends the coefficients, constant term first.
+	SumcheckCoefficients
+)
+
+// Sumcheck verifies rounds rounds of a sumcheck for claim over the boolean
+// hypercube. In every round it reads a univariate polynomial of the given
+// degree, checks that its sum over {0, 1} is the current claim, and reduces
+// the claim to its evaluation at a fresh challenge. It returns the challenges
+// and the final claim.
+func Sumcheck(api frontend.API, arthur gnark_nimue.Arthur, claim frontend.Variable, rounds int, degree int, encoding SumcheckEncoding) ([]frontend.Variable, frontend.Variable, error) {
+	randomness := make([]frontend.Variable, rounds)
+	for i := range rounds {
+		polynomial := make([]frontend.Variable, degree+1)
+		if err := arthur.FillNextScalars(polynomial); err != nil {
+			return nil, nil, err
+		}
+		if err := arthur.FillChallengeScalars(randomness[i : i+1]); err != nil {
+			return nil, nil, err
+		}
+
+		api.AssertIsEqual(claim, SumOverBool(api, polynomial, encoding))
+		claim = EvaluateSumcheckPolynomial(api, polynomial, encoding, randomness[i])
+	}
+	return randomness, claim, nil
+}
+
+// SumOverBool returns p(0) + p(1).
+func SumOverBool(api frontend.API, polynomial []frontend.Variable, encoding SumcheckEncoding) frontend.Variable {
+	if encoding == SumcheckEvaluations {
+		return api.Add(polynomial[0], polynomial[1])
+	}
+	sum := api.Mul(2, polynomial[0])
+	for _, c := range polynomial[1:] {
+		sum = api.Add(sum, c)
+	}
+	return sum
+}
+
+// EvaluateSumcheckPolynomial evaluates a round polynomial at point.
+func EvaluateSumcheckPolynomial(api frontend.API, polynomial []frontend.Variable, encoding SumcheckEncoding, point frontend.Variable) frontend.Variable {
+	if encoding == SumcheckEvaluations {
+		return EvaluateFromEvaluationList(api, polynomial, point)
+	}
+	return UnivarPoly(api, polynomial, []frontend.Variable{point})[0]
+}
+
+// EvaluateFromEvaluationList evaluates at point the polynomial of degree
+// len(evaluations)-1 with the given evaluations at 0, 1, ..., by Lagrange
+// interpolation. The coefficients are linear combinations of the evaluations
+// with constant weights, so only the final Horner evaluation costs
+// multiplications.
+func EvaluateFromEvaluationList(api frontend.API, evaluations []frontend.Variable, point frontend.Variable) frontend.Variable {
+	return UnivarPoly(api, CoefficientsFromEvaluationList(api, evaluations), []frontend.Variable{point})[0]
+}
+
+// CoefficientsFromEvaluationList returns the coefficients, constant term
+// first, of the polynomial with the given evaluations at 0, 1, ....
+func CoefficientsFromEvaluationList(api frontend.API, evaluations []frontend.Variable) []frontend.Variable {
+	numerators, denominators := LagrangeBasis(len(evaluations))
+	coefficients := make([]frontend.Variable, len(evaluations))
+	for k := range coefficients {
+		coefficients[k] = frontend.Variable(0)
+		for i := range evaluations {
+			if numerators[i][k].Sign() != 0 {
+				coefficients[k] = api.Add(coefficients[k], api.Div(api.Mul(evaluations[i], numerators[i][k]), denominators[i]))
+			}
+		}
+	}
+	return coefficients
+}
+
+// LagrangeBasis returns the Lagrange basis polynomials of the nodes 0, 1, ...,
+// n-1 as integer coefficients of prod_{j != i}(x - j), constant term first,
+// and the denominators prod_{j != i}(i - j), the inverses of the barycentric
+// weights.
+func LagrangeBasis(n int) ([][]*big.Int, []*big.Int) {
+	numerators := make([][]*big.Int, n)
+	denominators := make([]*big.Int, n)
+	for i := range n {
+		numerator := []*big.Int{big.NewInt(1)}
+		denominators[i] = big.NewInt(1)
+		for j := range n {
+			if j == i {
+				continue
+			}
+			// Multiply by (x - j).
+			next := make([]*big.Int, len(numerator)+1)
+			next[0] = new(big.Int)
+			for k := range numerator {
+				next[k+1] = new(big.Int).Set(numerator[k])
+				next[k].Sub(next[k], new(big.Int).Mul(numerator[k], big.NewInt(int64(j))))
+			}
+			numerator = next
+			denominators[i].Mul(denominators[i], big.NewInt(int64(i-j)))
+		}
+		numerators[i] = numerator
+	}
+	return numerators, denominators
+}
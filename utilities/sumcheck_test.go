@@ -0,0 +1,146 @@
+package utilities_test
+
+import (
+	"reilabs/whir-verifier-circuit/utilities"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/test"
+	gnark_nimue "github.com/reilabs/gnark-nimue"
+)
+
+// scriptedArthur reads the given scalars and squeezes the given challenges.
+type scriptedArthur struct {
+	gnark_nimue.Arthur
+	scalars    []frontend.Variable
+	challenges []frontend.Variable
+}
+
+func (a *scriptedArthur) FillNextScalars(out []frontend.Variable) error {
+	copy(out, a.scalars)
+	a.scalars = a.scalars[len(out):]
+	return nil
+}
+
+func (a *scriptedArthur) FillChallengeScalars(out []frontend.Variable) error {
+	copy(out, a.challenges)
+	a.challenges = a.challenges[len(out):]
+	return nil
+}
+
+type sumcheckCircuit struct {
+	degree      int
+	encoding    utilities.SumcheckEncoding
+	Claim       frontend.Variable
+	Polynomials []frontend.Variable
+	Challenges  []frontend.Variable
+	Final       frontend.Variable
+}
+
+func (c *sumcheckCircuit) Define(api frontend.API) error {
+	arthur := &scriptedArthur{scalars: c.Polynomials, challenges: c.Challenges}
+	randomness, final, err := utilities.Sumcheck(api, arthur, c.Claim, len(c.Challenges), c.degree, c.encoding)
+	if err != nil {
+		return err
+	}
+	for i := range randomness {
+		api.AssertIsEqual(randomness[i], c.Challenges[i])
+	}
+	api.AssertIsEqual(final, c.Final)
+	return nil
+}
+
+func TestSumcheck(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		degree      int
+		encoding    utilities.SumcheckEncoding
+		claim       int
+		polynomials []int
+		challenges  []int
+		final       int
+	}{
+		// 3 + X + X² at 0, 1, 2 sums to 8 and is 23 at 4, and 10 + 3X²
+		// sums to 23 and is 157 at 7.
+		{"degree 2 evaluations", 2, utilities.SumcheckEvaluations, 8, []int{3, 5, 9, 10, 13, 22}, []int{4, 7}, 157},
+		// 1 + 2X + 3X² + 4X³ sums to 11 and is 49 at 2, and
+		// 20 + 3X + 2X² + 4X³ sums to 49 and is 155 at 3.
+		{"degree 3 coefficients", 3, utilities.SumcheckCoefficients, 11, []int{1, 2, 3, 4, 20, 3, 2, 4}, []int{2, 3}, 155},
+		// The same polynomials as evaluations at 0, 1, 2, 3.
+		{"degree 3 evaluations", 3, utilities.SumcheckEvaluations, 11, []int{1, 10, 49, 142, 20, 29, 66, 155}, []int{2, 3}, 155},
+	} {
+		circuit := &sumcheckCircuit{
+			degree:      tc.degree,
+			encoding:    tc.encoding,
+			Polynomials: make([]frontend.Variable, len(tc.polynomials)),
+			Challenges:  make([]frontend.Variable, len(tc.challenges)),
+		}
+		assignment := func(polynomials []int, final int) *sumcheckCircuit {
+			res := &sumcheckCircuit{Claim: tc.claim, Final: final}
+			for _, x := range polynomials {
+				res.Polynomials = append(res.Polynomials, x)
+			}
+			for _, x := range tc.challenges {
+				res.Challenges = append(res.Challenges, x)
+			}
+			return res
+		}
+		if err := test.IsSolved(circuit, assignment(tc.polynomials, tc.final), ecc.BN254.ScalarField()); err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		}
+
+		// Shifting the second round polynomial by a constant breaks its sum
+		// over {0, 1}, whatever the final claim.
+		invalid := append([]int{}, tc.polynomials...)
+		shift := tc.degree + 1
+		for i := shift; i < len(invalid); i++ {
+			if tc.encoding == utilities.SumcheckEvaluations || i == shift {
+				invalid[i]++
+			}
+		}
+		for _, final := range []int{tc.final, tc.final + 1, tc.final + 2} {
+			if err := test.IsSolved(circuit, assignment(invalid, final), ecc.BN254.ScalarField()); err == nil {
+				t.Errorf("%s: invalid second round polynomial %v is accepted", tc.name, invalid[shift:])
+			}
+		}
+	}
+}
+
+type evaluationListCircuit struct {
+	Evaluations []frontend.Variable
+	Point       frontend.Variable
+	Value       frontend.Variable
+}
+
+func (c *evaluationListCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(utilities.EvaluateFromEvaluationList(api, c.Evaluations, c.Point), c.Value)
+	return nil
+}
+
+func TestEvaluateFromEvaluationList(t *testing.T) {
+	for _, tc := range []struct {
+		evaluations []frontend.Variable
+		point       int
+		value       int
+	}{
+		// 3 + X + X²
+		{[]frontend.Variable{3, 5, 9}, 4, 23},
+		// 1 + 2X + 3X² + 4X³
+		{[]frontend.Variable{1, 10, 49, 142}, 5, 586},
+		// Inside of the nodes.
+		{[]frontend.Variable{1, 10, 49, 142}, 2, 49},
+	} {
+		circuit := &evaluationListCircuit{Evaluations: make([]frontend.Variable, len(tc.evaluations))}
+		for _, value := range []int{tc.value, tc.value + 1} {
+			assignment := &evaluationListCircuit{Evaluations: tc.evaluations, Point: tc.point, Value: value}
+			err := test.IsSolved(circuit, assignment, ecc.BN254.ScalarField())
+			if value == tc.value && err != nil {
+				t.Errorf("%v at %d: %v", tc.evaluations, tc.point, err)
+			}
+			if value != tc.value && err == nil {
+				t.Errorf("%v at %d evaluates to %d", tc.evaluations, tc.point, value)
+			}
+		}
+	}
+}
@@ -5,11 +5,12 @@ import (
 	"math/big"
 	"reilabs/whir-verifier-circuit/typeConverters"
 
+	"github.com/consensys/gnark-crypto/ecc"
 	"github.com/consensys/gnark/frontend"
-	"github.com/consensys/gnark/std/lookup/logderivlookup"
 	"github.com/consensys/gnark/std/math/uints"
+	"github.com/consensys/gnark/std/multicommit"
+	"github.com/consensys/gnark/std/permutation/keccakf"
 	gnark_nimue "github.com/reilabs/gnark-nimue"
-	skyscraper "github.com/reilabs/gnark-skyscraper"
 )
 
 func MultivarPoly(coefs []frontend.Variable, vars []frontend.Variable, api frontend.API) frontend.Variable {
@@ -33,25 +34,31 @@ func UnivarPoly(api frontend.API, coefficients []frontend.Variable, points []fro
 	return results
 }
 
-func IndexOf(_ *big.Int, inputs []*big.Int, outputs []*big.Int) error {
-	if len(outputs) != 1 {
-		return fmt.Errorf("expecting one output")
-	}
-
+// Multiplicities counts how many times each table entry occurs among the
+// queries. The inputs are the size of the table, the table and the queries.
+// Repeated entries of the table are all counted at their first occurrence.
+// A query missing from the table is not counted, so the sums of the caller
+// differ and its assertion fails instead of the solver.
+func Multiplicities(_ *big.Int, inputs []*big.Int, outputs []*big.Int) error {
 	if len(inputs) == 0 {
 		return fmt.Errorf("inputs array cannot be empty")
 	}
-
-	target := inputs[0]
-
-	for i := 1; i < len(inputs); i++ {
-		if inputs[i].Cmp(target) == 0 {
-			outputs[0] = big.NewInt(int64(i - 1))
-			return nil
+	size := int(inputs[0].Int64())
+	if len(inputs) < 1+size || len(outputs) != size {
+		return fmt.Errorf("expecting a table of %d entries and as many outputs", size)
+	}
+	table := inputs[1 : 1+size]
+	for i := range outputs {
+		outputs[i].SetUint64(0)
+	}
+	for _, query := range inputs[1+size:] {
+		for i := range table {
+			if table[i].Cmp(query) == 0 {
+				outputs[i].Add(outputs[i], big.NewInt(1))
+				break
+			}
 		}
 	}
-
-	outputs[0] = big.NewInt(-1)
 	return nil
 }
 
@@ -77,59 +84,159 @@ func PrefixDecodePath[T any](prevPath []T, prefixLen uint64, suffix []T) []T {
 	}
 }
 
-func PoW(api frontend.API, sc *skyscraper.Skyscraper, arthur gnark_nimue.Arthur, difficulty int) ([]uints.U8, []uints.U8, error) {
+// PoWMode selects how a proof of work hash of a field hash is compared
+// against the difficulty.
+type PoWMode int
+
+const (
+	// PoWThreshold accepts hashes up to the modulus shifted right by the
+	// difficulty, so that a random field element passes with probability
+	// 2^-difficulty.
+	PoWThreshold PoWMode = iota
+	// PoWLeadingZeros accepts hashes whose 256 bit big endian encoding starts
+	// with difficulty zero bits, as the byte oriented grindings do.
+	PoWLeadingZeros
+)
+
+func ParsePoWMode(s string) (PoWMode, error) {
+	switch s {
+	case "threshold":
+		return PoWThreshold, nil
+	case "leading-zeros":
+		return PoWLeadingZeros, nil
+	}
+	return 0, fmt.Errorf("unknown proof of work mode %q, expected threshold or leading-zeros", s)
+}
+
+// PoWBound returns the largest hash accepted at the given difficulty. It
+// fails for difficulties no hash can meet.
+func PoWBound(difficulty int, mode PoWMode) (*big.Int, error) {
+	if difficulty < 0 {
+		return nil, fmt.Errorf("proof of work difficulty %d is negative", difficulty)
+	}
+	var bound *big.Int
+	switch mode {
+	case PoWThreshold:
+		bound = new(big.Int).Rsh(ecc.BN254.ScalarField(), uint(difficulty))
+	case PoWLeadingZeros:
+		if difficulty >= 256 {
+			return nil, fmt.Errorf("proof of work difficulty %d leaves no bits of the hash", difficulty)
+		}
+		bound = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), uint(256-difficulty)), big.NewInt(1))
+	default:
+		return nil, fmt.Errorf("unknown proof of work mode %d", mode)
+	}
+	if bound.Sign() == 0 {
+		return nil, fmt.Errorf("proof of work difficulty %d is above the %d bits of the field", difficulty, ecc.BN254.ScalarField().BitLen())
+	}
+	return bound, nil
+}
+
+// Compressor is a two to one hash of field elements, such as
+// skyscraper.Skyscraper.
+type Compressor interface {
+	Compress(left, right frontend.Variable) frontend.Variable
+}
+
+// PoWHasher checks that a nonce solves a proof of work challenge, such as
+// FieldPoW and KeccakPoW.
+type PoWHasher interface {
+	CheckPoW(challenge []uints.U8, nonce []uints.U8, difficulty int, mode PoWMode) error
+}
+
+func PoW(h PoWHasher, arthur gnark_nimue.Arthur, difficulty int, mode PoWMode) ([]uints.U8, []uints.U8, error) {
 	challenge := make([]uints.U8, 32)
 	if err := arthur.FillChallengeBytes(challenge); err != nil {
 		return nil, nil, err
 	}
-	// api.Println(challenge)
 	nonce := make([]uints.U8, 8)
-
 	if err := arthur.FillNextBytes(nonce); err != nil {
 		return nil, nil, err
 	}
-	// api.Println(nonce)
-	challengeFieldElement := typeConverters.LittleEndianFromUints(api, challenge)
-	nonceFieldElement := typeConverters.BigEndianFromUints(api, nonce)
-	// api.Println(nonceFieldElement)
-	CheckPoW(api, sc, challengeFieldElement, nonceFieldElement, difficulty)
+	if err := h.CheckPoW(challenge, nonce, difficulty, mode); err != nil {
+		return nil, nil, err
+	}
 	return challenge, nonce, nil
 }
 
-func CheckPoW(api frontend.API, sc *skyscraper.Skyscraper, challenge frontend.Variable, nonce frontend.Variable, difficulty int) error {
-	hash := sc.Compress(challenge, nonce)
-
-	d0, _ := new(big.Int).SetString("21888242871839275222246405745257275088548364400416034343698204186575808495617", 10)
-	d1, _ := new(big.Int).SetString("10944121435919637611123202872628637544274182200208017171849102093287904247808", 10)
-	d2, _ := new(big.Int).SetString("5472060717959818805561601436314318772137091100104008585924551046643952123904", 10)
-	d3, _ := new(big.Int).SetString("2736030358979909402780800718157159386068545550052004292962275523321976061952", 10)
-	d4, _ := new(big.Int).SetString("1368015179489954701390400359078579693034272775026002146481137761660988030976", 10)
-	d5, _ := new(big.Int).SetString("684007589744977350695200179539289846517136387513001073240568880830494015488", 10)
-	d6, _ := new(big.Int).SetString("342003794872488675347600089769644923258568193756500536620284440415247007744", 10)
-	d7, _ := new(big.Int).SetString("171001897436244337673800044884822461629284096878250268310142220207623503872", 10)
-	d8, _ := new(big.Int).SetString("85500948718122168836900022442411230814642048439125134155071110103811751936", 10)
-	d9, _ := new(big.Int).SetString("42750474359061084418450011221205615407321024219562567077535555051905875968", 10)
-	d10, _ := new(big.Int).SetString("21375237179530542209225005610602807703660512109781283538767777525952937984", 10)
-	d11, _ := new(big.Int).SetString("10687618589765271104612502805301403851830256054890641769383888762976468992", 10)
-	d12, _ := new(big.Int).SetString("5343809294882635552306251402650701925915128027445320884691944381488234496", 10)
-	d13, _ := new(big.Int).SetString("2671904647441317776153125701325350962957564013722660442345972190744117248", 10)
-	d14, _ := new(big.Int).SetString("1335952323720658888076562850662675481478782006861330221172986095372058624", 10)
-	d15, _ := new(big.Int).SetString("667976161860329444038281425331337740739391003430665110586493047686029312", 10)
-	d16, _ := new(big.Int).SetString("333988080930164722019140712665668870369695501715332555293246523843014656", 10)
-	d17, _ := new(big.Int).SetString("166994040465082361009570356332834435184847750857666277646623261921507328", 10)
-	d18, _ := new(big.Int).SetString("83497020232541180504785178166417217592423875428833138823311630960753664", 10)
-	d19, _ := new(big.Int).SetString("41748510116270590252392589083208608796211937714416569411655815480376832", 10)
-	d20, _ := new(big.Int).SetString("20874255058135295126196294541604304398105968857208284705827907740188416", 10)
-	d21, _ := new(big.Int).SetString("10437127529067647563098147270802152199052984428604142352913953870094208", 10)
-	d22, _ := new(big.Int).SetString("5218563764533823781549073635401076099526492214302071176456976935047104", 10)
-	d23, _ := new(big.Int).SetString("2609281882266911890774536817700538049763246107151035588228488467523552", 10)
-	d24, _ := new(big.Int).SetString("1304640941133455945387268408850269024881623053575517794114244233761776", 10)
-	d25, _ := new(big.Int).SetString("652320470566727972693634204425134512440811526787758897057122116880888", 10)
-	d26, _ := new(big.Int).SetString("326160235283363986346817102212567256220405763393879448528561058440444", 10)
-	d27, _ := new(big.Int).SetString("163080117641681993173408551106283628110202881696939724264280529220222", 10)
-
-	var arr = [28]*big.Int{d0, d1, d2, d3, d4, d5, d6, d7, d8, d9, d10, d11, d12, d13, d14, d15, d16, d17, d18, d19, d20, d21, d22, d23, d24, d25, d26, d27}
-	api.AssertIsLessOrEqual(hash, arr[difficulty])
+// FieldPoW compresses the challenge, read as a little-endian field element,
+// with the nonce, read big-endian.
+type FieldPoW struct {
+	API  frontend.API
+	Hash Compressor
+}
+
+func (p FieldPoW) CheckPoW(challenge []uints.U8, nonce []uints.U8, difficulty int, mode PoWMode) error {
+	challengeFieldElement := typeConverters.LittleEndianFromUints(p.API, challenge)
+	nonceFieldElement := typeConverters.BigEndianFromUints(p.API, nonce)
+	return CheckPoW(p.API, p.Hash, challengeFieldElement, nonceFieldElement, difficulty, mode)
+}
+
+func CheckPoW(api frontend.API, h Compressor, challenge frontend.Variable, nonce frontend.Variable, difficulty int, mode PoWMode) error {
+	bound, err := PoWBound(difficulty, mode)
+	if err != nil {
+		return err
+	}
+	hash := h.Compress(challenge, nonce)
+
+	if mode == PoWLeadingZeros {
+		// The bound is 2^n - 1, so decomposing the hash into n bits checks it.
+		// Hashes always fit into the bits of the field.
+		if n := bound.BitLen(); n < api.Compiler().FieldBitLen() {
+			api.ToBinary(hash, n)
+		}
+		return nil
+	}
+	api.AssertIsLessOrEqual(hash, bound)
+	return nil
+}
+
+// KeccakPoWBound returns the largest first lane KeccakPoW accepts at the
+// given difficulty, one below the threshold 2^(64-difficulty) of nimue-pow.
+// Past 64 bits the threshold rounds up to 1, so only a zero lane is accepted.
+func KeccakPoWBound(difficulty int) (*big.Int, error) {
+	if difficulty < 0 {
+		return nil, fmt.Errorf("proof of work difficulty %d is negative", difficulty)
+	}
+	one := big.NewInt(1)
+	return new(big.Int).Sub(new(big.Int).Lsh(one, uint(max(64-difficulty, 0))), one), nil
+}
+
+// KeccakPoW checks the Keccak grinding of nimue-pow: the challenge fills the
+// first four lanes of a zero Keccak-f[1600] state and the nonce, sent
+// big-endian, the fifth, and the nonce is accepted when the first lane of the
+// permuted state is below 2^(64-difficulty). The mode only applies to the
+// field hashes.
+type KeccakPoW struct {
+	API  frontend.API
+	UAPI *uints.BinaryField[uints.U64]
+}
+
+func (p KeccakPoW) CheckPoW(challenge []uints.U8, nonce []uints.U8, difficulty int, _ PoWMode) error {
+	if _, err := KeccakPoWBound(difficulty); err != nil {
+		return err
+	}
+	var state [25]uints.U64
+	for i := range state {
+		state[i] = uints.NewU64(0)
+	}
+	for i := range 4 {
+		copy(state[i][:], challenge[8*i:8*i+8])
+	}
+	for i := range nonce {
+		state[4][len(nonce)-1-i] = p.UAPI.ByteValueOf(nonce[i].Val)
+	}
+	state = keccakf.Permute(p.UAPI, state)
+
+	// The lanes are little-endian, so the top bits are in the last bytes.
+	lane := state[0]
+	zeroBits := min(difficulty, 64)
+	for i := range zeroBits / 8 {
+		p.API.AssertIsEqual(lane[7-i].Val, 0)
+	}
+	if rest := zeroBits % 8; rest > 0 {
+		p.API.ToBinary(lane[7-zeroBits/8].Val, 8-rest)
+	}
 	return nil
 }
 
@@ -141,14 +248,6 @@ func EqPolyOutside(api frontend.API, coords []frontend.Variable, point []fronten
 	return acc
 }
 
-func EvaluateQuadraticPolynomialFromEvaluationList(api frontend.API, evaluations []frontend.Variable, point frontend.Variable) (ans frontend.Variable) {
-	inv2 := api.Inverse(2)
-	b0 := evaluations[0]
-	b1 := api.Mul(api.Add(api.Neg(evaluations[2]), api.Mul(4, evaluations[1]), api.Mul(-3, evaluations[0])), inv2)
-	b2 := api.Mul(api.Add(evaluations[2], api.Mul(-2, evaluations[1]), evaluations[0]), inv2)
-	return api.Add(api.Mul(point, point, b2), api.Mul(point, b1), b0)
-}
-
 func Exponent(api frontend.API, uapi *uints.BinaryField[uints.U64], X frontend.Variable, Y uints.U64) frontend.Variable {
 	output := frontend.Variable(1)
 	bits := api.ToBinary(uapi.ToValue(Y))
@@ -160,9 +259,30 @@ func Exponent(api frontend.API, uapi *uints.BinaryField[uints.U64], X frontend.V
 	return output
 }
 
-func CheckSumOverBool(api frontend.API, value frontend.Variable, polyEvals []frontend.Variable) {
-	sumOverBools := api.Add(polyEvals[0], polyEvals[1])
-	api.AssertIsEqual(value, sumOverBools)
+// FixedBaseExponent returns base^exponent for an exponent of nbBits bits.
+// The exponent is split in windows of two bits, each selecting one of the
+// powers 1, b, b^2, b^3 of b = base^(4^i) with a Lookup2. With a constant
+// base the powers are constants, so every window costs one constraint for
+// the lookup and one for the product on top of the decomposition.
+func FixedBaseExponent(api frontend.API, base frontend.Variable, exponent frontend.Variable, nbBits int) frontend.Variable {
+	if nbBits == 0 {
+		api.AssertIsEqual(exponent, 0)
+		return 1
+	}
+	bits := api.ToBinary(exponent, nbBits)
+	output := frontend.Variable(1)
+	power := base
+	for i := 0; i < nbBits; i += 2 {
+		if i+1 == nbBits {
+			output = api.Mul(output, api.Select(bits[i], power, 1))
+			break
+		}
+		square := api.Mul(power, power)
+		cube := api.Mul(square, power)
+		output = api.Mul(output, api.Lookup2(bits[i], bits[i+1], 1, power, square, cube))
+		power = api.Mul(square, square)
+	}
+	return output
 }
 
 func ExpandRandomness(api frontend.API, base frontend.Variable, len int) []frontend.Variable {
@@ -185,28 +305,85 @@ func ExpandFromUnivariate(api frontend.API, base frontend.Variable, len int) []f
 	return res
 }
 
-func IsSubset(api frontend.API, uapi *uints.BinaryField[uints.U64], arthur gnark_nimue.Arthur, indexes []frontend.Variable, merkleIndexes []uints.U64) error {
-	dedupedLUT := logderivlookup.New(api)
-	inputArr := make([]frontend.Variable, len(merkleIndexes)+1)
-
+// IsSubset asserts that the multiset of indexes is contained in the opened
+// merkleIndexes with a single log-derivative argument: for a challenge ch
+// committed to after both sides and the multiplicities m given by a hint,
+// Σ m_j/(ch - t_j) = Σ 1/(ch - x_i).
+//
+// The opened indexes, of nbBits bits each, must also be sorted and distinct,
+// so that the prover cannot pad the openings with repeated leaves. Only the
+// universal circuit pads them on purpose, by repeating the last opening, so
+// when padded is set the indexes may end in a tail of copies of the last
+// distinct one, see PaddedTail.
+func IsSubset(api frontend.API, uapi *uints.BinaryField[uints.U64], indexes []frontend.Variable, merkleIndexes []uints.U64, nbBits int, padded bool) error {
+	table := make([]frontend.Variable, len(merkleIndexes))
 	for j, index := range merkleIndexes {
-		dedupedLUT.Insert(uapi.ToValue(index))
-		inputArr[1+j] = uapi.ToValue(index)
+		table[j] = uapi.ToValue(index)
 	}
 
-	for _, x := range indexes {
-		inputArr[0] = x
-		res, newerr := api.Compiler().NewHint(IndexOf, 1, inputArr...)
-		if newerr != nil {
-			return newerr
+	gaps := make([]frontend.Variable, len(table))
+	for j := range gaps {
+		gaps[j] = 1
+	}
+	if padded {
+		for j, tail := range PaddedTail(api, table) {
+			gaps[j] = api.Sub(1, tail)
 		}
-		searchRes := dedupedLUT.Lookup(res[0])
-		//api.Println(searchRes...)
-		api.AssertIsEqual(x, searchRes[0])
 	}
+	for j := 1; j < len(table); j++ {
+		api.ToBinary(api.Sub(table[j], table[j-1], gaps[j]), nbBits)
+	}
+
+	if len(indexes) == 0 {
+		return nil
+	}
+	inputs := append([]frontend.Variable{len(table)}, table...)
+	inputs = append(inputs, indexes...)
+	exps, err := api.Compiler().NewHint(Multiplicities, len(table), inputs...)
+	if err != nil {
+		return err
+	}
+
+	// The argument joins the commitment once the circuit is defined, as the
+	// lookup tables do, since the commitment closes to any table created
+	// after it. The callback gets the builder, while the assertion has to go
+	// through api, which may only enforce it conditionally.
+	committed := append(append(append([]frontend.Variable{}, table...), indexes...), exps...)
+	api.Compiler().Defer(func(builder frontend.API) error {
+		multicommit.WithCommitment(builder, func(_ frontend.API, ch frontend.Variable) error {
+			lhs := frontend.Variable(0)
+			for j := range table {
+				lhs = api.Add(lhs, api.Div(exps[j], api.Sub(ch, table[j])))
+			}
+			rhs := frontend.Variable(0)
+			for _, x := range indexes {
+				rhs = api.Add(rhs, api.Div(1, api.Sub(ch, x)))
+			}
+			api.AssertIsEqual(lhs, rhs)
+			return nil
+		}, committed...)
+		return nil
+	})
 	return nil
 }
 
+// PaddedTail returns whether each of the indexes repeats the one before it,
+// asserting that the repeats form a tail: once an index repeats, all the
+// following ones do. Padding the openings only ever appends such a tail, so
+// a repeat in the middle is not padding.
+func PaddedTail(api frontend.API, indexes []frontend.Variable) []frontend.Variable {
+	tail := make([]frontend.Variable, len(indexes))
+	if len(indexes) == 0 {
+		return tail
+	}
+	tail[0] = 0
+	for j := 1; j < len(indexes); j++ {
+		tail[j] = api.IsZero(api.Sub(indexes[j], indexes[j-1]))
+		api.AssertIsEqual(api.Mul(tail[j-1], api.Sub(1, tail[j])), 0)
+	}
+	return tail
+}
+
 func DotProduct(api frontend.API, a []frontend.Variable, b []frontend.Variable) frontend.Variable {
 	var acc = frontend.Variable(0)
 	for i := range a {
@@ -0,0 +1,130 @@
+package utilities_test
+
+import (
+	"math/big"
+	"reilabs/whir-verifier-circuit/utilities"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/math/uints"
+	"github.com/consensys/gnark/test"
+)
+
+type isSubsetCircuit struct {
+	padded        bool
+	Indexes       []frontend.Variable
+	MerkleIndexes []uints.U64
+}
+
+func (c *isSubsetCircuit) Define(api frontend.API) error {
+	uapi, err := uints.New[uints.U64](api)
+	if err != nil {
+		return err
+	}
+	return utilities.IsSubset(api, uapi, c.Indexes, c.MerkleIndexes, 8, c.padded)
+}
+
+func TestIsSubset(t *testing.T) {
+	for _, tc := range []struct {
+		name          string
+		indexes       []uint64
+		merkleIndexes []uint64
+		padded        bool
+		valid         bool
+	}{
+		{"subset", []uint64{9, 2, 9, 5}, []uint64{2, 5, 9}, false, true},
+		{"missing index", []uint64{9, 3}, []uint64{2, 5, 9}, false, false},
+		{"duplicate opening", []uint64{2, 5}, []uint64{2, 5, 5}, false, false},
+		{"unsorted openings", []uint64{2, 5, 9}, []uint64{2, 9, 5}, false, false},
+		{"padded openings", []uint64{5, 2, 2}, []uint64{2, 5, 5, 5}, true, true},
+		{"repeat before the padding", []uint64{2, 5, 9}, []uint64{2, 2, 5, 9}, true, false},
+		{"missing index with padding", []uint64{3}, []uint64{2, 5, 5}, true, false},
+	} {
+		circuit := &isSubsetCircuit{
+			padded:        tc.padded,
+			Indexes:       make([]frontend.Variable, len(tc.indexes)),
+			MerkleIndexes: make([]uints.U64, len(tc.merkleIndexes)),
+		}
+		assignment := &isSubsetCircuit{padded: tc.padded}
+		for _, index := range tc.indexes {
+			assignment.Indexes = append(assignment.Indexes, index)
+		}
+		for _, index := range tc.merkleIndexes {
+			assignment.MerkleIndexes = append(assignment.MerkleIndexes, uints.NewU64(index))
+		}
+		err := test.IsSolved(circuit, assignment, ecc.BN254.ScalarField())
+		if tc.valid && err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("%s: %v is accepted as a subset of %v", tc.name, tc.indexes, tc.merkleIndexes)
+		}
+	}
+}
+
+func TestMultiplicities(t *testing.T) {
+	// A table of 2, 5, 5, 9 queried with 9, 5, 9, 5, 3.
+	inputs := bigs(4, 2, 5, 5, 9, 9, 5, 9, 5, 3)
+	outputs := bigs(0, 0, 0, 0)
+	if err := utilities.Multiplicities(nil, inputs, outputs); err != nil {
+		t.Fatal(err)
+	}
+	// The repeated 5 is counted at its first occurrence and the missing 3
+	// is not counted.
+	for i, expected := range []int64{0, 2, 0, 2} {
+		if outputs[i].Int64() != expected {
+			t.Errorf("entry %d occurs %d times, expected %d", i, outputs[i].Int64(), expected)
+		}
+	}
+	if err := utilities.Multiplicities(nil, bigs(4, 2, 5), outputs); err == nil {
+		t.Error("a table shorter than its size is accepted")
+	}
+}
+
+func bigs(values ...int64) []*big.Int {
+	res := make([]*big.Int, len(values))
+	for i, v := range values {
+		res[i] = big.NewInt(v)
+	}
+	return res
+}
+
+type fixedBaseExponentCircuit struct {
+	nbBits   int
+	base     int64
+	Exponent frontend.Variable
+	Power    frontend.Variable
+}
+
+func (c *fixedBaseExponentCircuit) Define(api frontend.API) error {
+	api.AssertIsEqual(utilities.FixedBaseExponent(api, c.base, c.Exponent, c.nbBits), c.Power)
+	return nil
+}
+
+func TestFixedBaseExponent(t *testing.T) {
+	for _, tc := range []struct {
+		nbBits   int
+		exponent int64
+		valid    bool
+	}{
+		{4, 13, true},
+		{5, 29, true},
+		{5, 0, true},
+		{0, 0, true},
+		{4, 16, false},
+		{5, 37, false},
+		{0, 1, false},
+	} {
+		power := new(big.Int).Exp(big.NewInt(3), big.NewInt(tc.exponent), ecc.BN254.ScalarField())
+		circuit := &fixedBaseExponentCircuit{nbBits: tc.nbBits, base: 3}
+		assignment := &fixedBaseExponentCircuit{Exponent: tc.exponent, Power: power}
+		err := test.IsSolved(circuit, assignment, ecc.BN254.ScalarField())
+		if tc.valid && err != nil {
+			t.Errorf("3^%d with %d bits: %v", tc.exponent, tc.nbBits, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("exponent %d is accepted with %d bits", tc.exponent, tc.nbBits)
+		}
+	}
+}
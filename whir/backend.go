@@ -0,0 +1,204 @@
+package whir
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"reilabs/whir-verifier-circuit/utilities"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/backend/plonk"
+	"github.com/consensys/gnark/constraint"
+	"github.com/consensys/gnark/constraint/solver"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/frontend/cs/scs"
+	"github.com/consensys/gnark/test/unsafekzg"
+	"golang.org/x/crypto/sha3"
+)
+
+// Backend selects the proof system the verifier circuit is proved with.
+type Backend string
+
+const (
+	// BackendGroth16 needs a trusted setup for every circuit shape.
+	BackendGroth16 Backend = "groth16"
+	// BackendPlonk uses a universal setup. The KZG SRS is generated locally
+	// with unsafekzg, so it is fit for testing and benchmarking only.
+	BackendPlonk Backend = "plonk"
+)
+
+func ParseBackend(s string) (Backend, error) {
+	switch backend := Backend(s); backend {
+	case BackendGroth16, BackendPlonk:
+		return backend, nil
+	}
+	return "", fmt.Errorf("unknown backend %q, expected groth16 or plonk", s)
+}
+
+// Proof is a groth16.Proof or a plonk.Proof, depending on the backend.
+type Proof interface {
+	io.WriterTo
+	io.ReaderFrom
+}
+
+// ProvingKey is a groth16.ProvingKey or a plonk.ProvingKey.
+type ProvingKey interface {
+	io.WriterTo
+	io.ReaderFrom
+}
+
+// VerifyingKey is a groth16.VerifyingKey or a plonk.VerifyingKey.
+type VerifyingKey interface {
+	io.WriterTo
+	io.ReaderFrom
+}
+
+func compileCircuit(circuit *Circuit, b Backend) (constraint.ConstraintSystem, error) {
+	builder := r1cs.NewBuilder
+	if b == BackendPlonk {
+		builder = scs.NewBuilder
+	}
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), builder, circuit)
+	if err != nil {
+		return nil, WithStage(StageCompile, err)
+	}
+	return ccs, nil
+}
+
+func newConstraintSystem(b Backend) constraint.ConstraintSystem {
+	if b == BackendPlonk {
+		return plonk.NewCS(ecc.BN254)
+	}
+	return groth16.NewCS(ecc.BN254)
+}
+
+func newProvingKey(b Backend) ProvingKey {
+	if b == BackendPlonk {
+		return plonk.NewProvingKey(ecc.BN254)
+	}
+	return groth16.NewProvingKey(ecc.BN254)
+}
+
+func newVerifyingKey(b Backend) VerifyingKey {
+	if b == BackendPlonk {
+		return plonk.NewVerifyingKey(ecc.BN254)
+	}
+	return groth16.NewVerifyingKey(ecc.BN254)
+}
+
+func newProof(b Backend) Proof {
+	if b == BackendPlonk {
+		return plonk.NewProof(ecc.BN254)
+	}
+	return groth16.NewProof(ecc.BN254)
+}
+
+func setupKeys(ccs constraint.ConstraintSystem, b Backend) (ProvingKey, VerifyingKey, error) {
+	if b == BackendPlonk {
+		srs, srsLagrange, err := unsafekzg.NewSRS(ccs)
+		if err != nil {
+			return nil, nil, WithStage(StageSetup, err)
+		}
+		pk, vk, err := plonk.Setup(ccs, srs, srsLagrange)
+		if err != nil {
+			return nil, nil, WithStage(StageSetup, err)
+		}
+		return pk, vk, nil
+	}
+	pk, vk, err := groth16.Setup(ccs)
+	if err != nil {
+		return nil, nil, WithStage(StageSetup, err)
+	}
+	return pk, vk, nil
+}
+
+// proverOptions hash the commitments of Groth16 proofs with Keccak-256, as the
+// Solidity verifier exported by ExportSolidity expects. The PLONK verifier
+// gnark exports derives its commitment challenges with the default
+// hash-to-field of gnark, so PLONK proofs keep it.
+func proverOptions(b Backend) []backend.ProverOption {
+	opts := []backend.ProverOption{backend.WithSolverOptions(solver.WithHints(utilities.Multiplicities))}
+	if b == BackendGroth16 {
+		opts = append(opts, backend.WithProverHashToFieldFunction(sha3.NewLegacyKeccak256()))
+	}
+	return opts
+}
+
+// verifierOptions are the verifier side of proverOptions.
+func verifierOptions(b Backend) []backend.VerifierOption {
+	if b == BackendGroth16 {
+		return []backend.VerifierOption{backend.WithVerifierHashToFieldFunction(sha3.NewLegacyKeccak256())}
+	}
+	return nil
+}
+
+func proveCircuit(ccs constraint.ConstraintSystem, pk ProvingKey, assignment *Circuit, b Backend) (Proof, error) {
+	witness, err := frontend.NewWitness(assignment, ecc.BN254.ScalarField())
+	if err != nil {
+		return nil, WithStage(StageWitness, err)
+	}
+	opts := proverOptions(b)
+
+	var proof Proof
+	if b == BackendPlonk {
+		proof, err = plonk.Prove(ccs, pk.(plonk.ProvingKey), witness, opts...)
+	} else {
+		proof, err = groth16.Prove(ccs, pk.(groth16.ProvingKey), witness, opts...)
+	}
+	if err != nil {
+		return nil, WithStage(StageProve, err)
+	}
+	return proof, nil
+}
+
+func verifyProof(proof Proof, vk VerifyingKey, assignment *Circuit, b Backend) error {
+	publicWitness, err := frontend.NewWitness(assignment, ecc.BN254.ScalarField(), frontend.PublicOnly())
+	if err != nil {
+		return WithStage(StageWitness, err)
+	}
+	opts := verifierOptions(b)
+
+	if b == BackendPlonk {
+		p, ok := proof.(plonk.Proof)
+		if !ok {
+			return WithStage(StageVerify, fmt.Errorf("expected a PLONK proof, got %T", proof))
+		}
+		return WithStage(StageVerify, plonk.Verify(p, vk.(plonk.VerifyingKey), publicWitness, opts...))
+	}
+	p, ok := proof.(groth16.Proof)
+	if !ok {
+		return WithStage(StageVerify, fmt.Errorf("expected a Groth16 proof, got %T", proof))
+	}
+	return WithStage(StageVerify, groth16.Verify(p, vk.(groth16.VerifyingKey), publicWitness, opts...))
+}
+
+func writeArtifact(path string, artifact io.WriterTo) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if _, err := artifact.WriteTo(w); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func readArtifact(path string, artifact io.ReaderFrom) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = artifact.ReadFrom(bufio.NewReader(f))
+	return err
+}
@@ -0,0 +1,237 @@
+package whir
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/consensys/gnark/constraint"
+)
+
+// circuitShape holds everything that ends up baked into the compiled
+// constraint system. Two inputs with the same shape compile to the same
+// circuit, so they can share the constraint system and the keys.
+type circuitShape struct {
+	LogNumConstraints   int
+	NRounds             int
+	NVars               int
+	FoldingFactor       []int
+	OODSamples          []int
+	NumQueries          []int
+	PowBits             []int
+	FinalQueries        int
+	FinalPowBits        int
+	FinalFoldingPowBits int
+	DomainGenerator     string
+	Rate                int
+	IOPattern           string
+	TranscriptLen       int
+	NumStatements       int
+	MerkleHash          string
+	TranscriptHash      string
+	PoWHash             string
+	LeafHash            string
+	MerkleArity         int
+
+	// The matrices are compiled in as constants, so their entries are part of
+	// the shape and not only their dimensions.
+	PublicInputs uint64
+	Witnesses    uint64
+	Constraints  uint64
+	Interner     string
+	A            SparseMatrix
+	B            SparseMatrix
+	C            SparseMatrix
+
+	// The prover deduplicates the queried leaves, so the number of openings
+	// in each round depends on the proof and changes the witness layout.
+	FirstRoundPaths []pathsShape
+	MerklePaths     []pathsShape
+
+	// Shared nodes compile the opened leaf indexes into the circuit, so such
+	// a shape, and the keys cached under it, belong to a single proof.
+	FirstRoundLayout [][]uint64 `json:",omitempty"`
+	MerkleLayout     [][]uint64 `json:",omitempty"`
+
+	// The universal shapes take the batch size from the proof and everything
+	// else from the settings.
+	BatchSize int `json:",omitempty"`
+
+	Settings settings
+}
+
+type pathsShape struct {
+	Leaves     int
+	LeafSize   int
+	TreeHeight int
+}
+
+func shapeOfPaths(proofElements []ProofElement) []pathsShape {
+	shapes := make([]pathsShape, len(proofElements))
+	for i, element := range proofElements {
+		shapes[i] = pathsShape{Leaves: len(element.A.LeafIndexes)}
+		if len(element.A.AuthPathsSuffixes) > 0 {
+			shapes[i].TreeHeight = len(element.A.AuthPathsSuffixes[0])
+		}
+		if len(element.B) > 0 {
+			shapes[i].LeafSize = len(element.B[0])
+		}
+	}
+	return shapes
+}
+
+// circuitKey returns a hex encoded hash of the circuit shape of the given
+// inputs, used to name the cached artifacts.
+func circuitKey(proof ProofObject, cfg Config, r1cs R1CS, s settings) (string, error) {
+	shape := circuitShape{
+		LogNumConstraints:   cfg.LogNumConstraints,
+		NRounds:             cfg.NRounds,
+		NVars:               cfg.NVars,
+		FoldingFactor:       cfg.FoldingFactor,
+		OODSamples:          cfg.OODSamples,
+		NumQueries:          cfg.NumQueries,
+		PowBits:             cfg.PowBits,
+		FinalQueries:        cfg.FinalQueries,
+		FinalPowBits:        cfg.FinalPowBits,
+		FinalFoldingPowBits: cfg.FinalFoldingPowBits,
+		DomainGenerator:     cfg.DomainGenerator,
+		Rate:                cfg.Rate,
+		IOPattern:           cfg.IOPattern,
+		TranscriptLen:       cfg.TranscriptLen,
+		NumStatements:       len(proof.StatementValuesAtRandomPoint),
+		MerkleHash:          cfg.MerkleHash,
+		TranscriptHash:      cfg.TranscriptHash,
+		PoWHash:             cfg.PoWHash,
+		LeafHash:            cfg.LeafHash,
+		MerkleArity:         cfg.MerkleArity,
+		PublicInputs:        r1cs.PublicInputs,
+		Witnesses:           r1cs.Witnesses,
+		Constraints:         r1cs.Constraints,
+		Interner:            r1cs.Interner.Values,
+		A:                   r1cs.A,
+		B:                   r1cs.B,
+		C:                   r1cs.C,
+		FirstRoundPaths:     shapeOfPaths(proof.FirstRoundPaths),
+		MerklePaths:         shapeOfPaths(proof.MerklePaths),
+		Settings:            s,
+	}
+	if s.SharedNodes {
+		shape.FirstRoundLayout, shape.MerkleLayout = pathsLayout(proof.FirstRoundPaths), pathsLayout(proof.MerklePaths)
+	}
+	// The universal circuit takes the matrices as inputs and pads the
+	// openings to the number of queries, so neither is part of its shape.
+	// Given its shapes, it does not depend on the params of the proof either.
+	if s.Universal != nil {
+		shape.Witnesses, shape.Constraints, shape.Interner = 0, 0, ""
+		shape.A, shape.B, shape.C = SparseMatrix{}, SparseMatrix{}, SparseMatrix{}
+		shape.FirstRoundPaths, shape.MerklePaths = universalPaths(proof, cfg)
+		if len(s.Universal.Shapes) > 0 {
+			shape = circuitShape{PublicInputs: r1cs.PublicInputs, BatchSize: len(proof.FirstRoundPaths), Settings: s}
+		}
+	}
+
+	encoded, err := json.Marshal(shape)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+type artifactPaths struct {
+	ccs string
+	pk  string
+	vk  string
+}
+
+func cachedArtifacts(dir string, key string) (artifactPaths, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return artifactPaths{}, err
+	}
+	base := filepath.Join(dir, key)
+	return artifactPaths{
+		ccs: base + ".ccs",
+		pk:  base + ".pk",
+		vk:  base + ".vk",
+	}, nil
+}
+
+func artifactExists(path string) (bool, error) {
+	_, err := os.Stat(path)
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return false, err
+}
+
+// loadOrCompile reads the constraint system from path, compiling the circuit
+// and storing the result there if it is not cached yet.
+func loadOrCompile(path string, circuit *Circuit, b Backend) (constraint.ConstraintSystem, error) {
+	exists, err := artifactExists(path)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		log.Printf("loading constraint system from %s", path)
+		ccs := newConstraintSystem(b)
+		if err := readArtifact(path, ccs); err != nil {
+			return nil, err
+		}
+		return ccs, nil
+	}
+
+	ccs, err := compileCircuit(circuit, b)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("compiled %s circuit with %d constraints", b, ccs.GetNbConstraints())
+	if err := writeArtifact(path, ccs); err != nil {
+		return nil, err
+	}
+	return ccs, nil
+}
+
+// loadOrSetup reads the key pair from pkPath and vkPath, running the setup
+// for ccs and storing the keys there if either of them is not cached yet.
+func loadOrSetup(pkPath string, vkPath string, ccs constraint.ConstraintSystem, b Backend) (ProvingKey, VerifyingKey, error) {
+	pkExists, err := artifactExists(pkPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	vkExists, err := artifactExists(vkPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if pkExists && vkExists {
+		log.Printf("loading keys from %s and %s", pkPath, vkPath)
+		pk := newProvingKey(b)
+		if err := readArtifact(pkPath, pk); err != nil {
+			return nil, nil, err
+		}
+		vk := newVerifyingKey(b)
+		if err := readArtifact(vkPath, vk); err != nil {
+			return nil, nil, err
+		}
+		return pk, vk, nil
+	}
+
+	pk, vk, err := setupKeys(ccs, b)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := writeArtifact(pkPath, pk); err != nil {
+		return nil, nil, err
+	}
+	if err := writeArtifact(vkPath, vk); err != nil {
+		return nil, nil, err
+	}
+	return pk, vk, nil
+}
@@ -0,0 +1,762 @@
+package whir
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"math/bits"
+	"reilabs/whir-verifier-circuit/native"
+	"reilabs/whir-verifier-circuit/utilities"
+	"slices"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/math/uints"
+)
+
+// CheckError names the step of the WHIR verifier at which Check rejected a
+// proof. Steps are named after the circuit functions they replay.
+type CheckError struct {
+	Step string
+	Err  error
+}
+
+func (e *CheckError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Step, e.Err)
+}
+
+func (e *CheckError) Unwrap() error {
+	return e.Err
+}
+
+func reject(step string, format string, args ...any) error {
+	return &CheckError{Step: step, Err: fmt.Errorf(format, args...)}
+}
+
+func rejectErr(step string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &CheckError{Step: step, Err: err}
+}
+
+// Check runs the WHIR verifier natively over the witness the circuit would be
+// proved with. It replays Circuit.Define step by step, so a proof it accepts
+// satisfies the circuit and a proof it rejects reports where it fails, in
+// seconds rather than after a full prove.
+func (v *Verifier) Check() error {
+	return WithStage(StageCheck, checkCircuit(&v.assignment))
+}
+
+type nativeChecker struct {
+	circuit  *Circuit
+	arthur   native.Arthur
+	merkle   nativeMerkleHasher
+	matrices [][]MatrixCell
+}
+
+func checkCircuit(circuit *Circuit) error {
+	if len(circuit.Shapes) > 0 {
+		return checkShapes(circuit)
+	}
+	arthur, err := newNativeArthur(circuit)
+	if err != nil {
+		return rejectErr("initializeComponents", err)
+	}
+	merkle, err := newNativeMerkleHasher(circuit.MerkleHash, circuit.LeafHash)
+	if err != nil {
+		return rejectErr("initializeComponents", err)
+	}
+	matrices, err := nativeMatrices(circuit)
+	if err != nil {
+		return err
+	}
+	c := &nativeChecker{circuit: circuit, arthur: arthur, merkle: merkle, matrices: matrices}
+
+	tRand, spRand, savedValForSumcheck, err := c.sumcheckForR1CSIOP()
+	if err != nil {
+		return err
+	}
+
+	rootHashes, batchingRandomness, initialOODQueries, initialOODAnswers, err := c.parseBatchedCommitment()
+	if err != nil {
+		return err
+	}
+	initialOODs := nativeOODAnswers(initialOODAnswers, batchingRandomness)
+
+	if len(circuit.PublicInputs) > 0 {
+		if err := nativeBindPublicInputs(circuit, initialOODQueries[0]); err != nil {
+			return err
+		}
+	}
+
+	initialCombinationRandomness, lastEval, initialSumcheckFoldingRandomness, err := c.initialSumcheck(initialOODs)
+	if err != nil {
+		return err
+	}
+
+	firstRoundLeaves := make([][][]fr.Element, len(circuit.FirstRoundPaths.Leaves))
+	for i := range circuit.FirstRoundPaths.Leaves {
+		firstRoundLeaves[i] = elementMatrix(circuit.FirstRoundPaths.Leaves[i])
+	}
+	computedFolded := nativeCombineFirstRoundLeaves(firstRoundLeaves, batchingRandomness)
+	computedFold := nativeFoldLeaves(circuit, computedFolded, uint64s(circuit.FirstRoundPaths.LeafIndexes[0]), initialSumcheckFoldingRandomness, 0)
+
+	nRounds := len(circuit.RoundParametersOODSamples)
+	oodPoints := make([][]fr.Element, nRounds)
+	stirChallengesPoints := make([][]fr.Element, nRounds)
+	combinationRandomness := make([][]fr.Element, nRounds)
+
+	startingDomainGenerator := toElement(circuit.StartingDomainBackingDomainGenerator)
+	expDomainGenerator := native.Exponent(startingDomainGenerator, uint64(1<<circuit.FoldingFactorArray[0]))
+	domainSize := circuit.DomainSize
+	totalFoldingRandomness := initialSumcheckFoldingRandomness
+	rootHashList := make([][]byte, nRounds)
+
+	for r := range nRounds {
+		if rootHashList[r], err = c.merkle.readRoot(c.arthur); err != nil {
+			return rejectErr(fmt.Sprintf("round %d root", r), err)
+		}
+
+		roundOODAnswers := []fr.Element{}
+		if n := circuit.RoundParametersOODSamples[r]; n > 0 {
+			oodPoints[r] = make([]fr.Element, n)
+			roundOODAnswers = make([]fr.Element, n)
+			if err := c.arthur.FillChallengeScalars(oodPoints[r]); err != nil {
+				return rejectErr(fmt.Sprintf("FillInOODPointsAndAnswers (round %d)", r), err)
+			}
+			if err := c.arthur.FillNextScalars(roundOODAnswers); err != nil {
+				return rejectErr(fmt.Sprintf("FillInOODPointsAndAnswers (round %d)", r), err)
+			}
+		}
+
+		stirChallengeIndexes, err := c.getStirChallenges(circuit.RoundParametersNumOfQueries[r], domainSize, r)
+		if err != nil {
+			return err
+		}
+
+		var leafIndexes []uint64
+		if r == 0 {
+			for i := range circuit.FirstRoundPaths.Leaves {
+				indexes := uint64s(circuit.FirstRoundPaths.LeafIndexes[i])
+				step := fmt.Sprintf("ValidateFirstRound (batch %d)", i)
+				if err := checkLayout(step, circuit.FirstRoundPaths, i, indexes); err != nil {
+					return err
+				}
+				if err := c.verifyMerkleTreeProofs(step, indexes, firstRoundLeaves[i], circuit.FirstRoundPaths.LeafSiblingHashes[i], circuit.FirstRoundPaths.AuthPaths[i], rootHashes[i]); err != nil {
+					return err
+				}
+				if err := nativeIsSubset(step, stirChallengeIndexes, indexes, circuit.Universal); err != nil {
+					return err
+				}
+			}
+			leafIndexes = uint64s(circuit.FirstRoundPaths.LeafIndexes[r])
+		} else {
+			leafIndexes = uint64s(circuit.MerklePaths.LeafIndexes[r-1])
+			step := fmt.Sprintf("VerifyMerkleTreeProofs (round %d)", r)
+			if err := checkLayout(step, circuit.MerklePaths, r-1, leafIndexes); err != nil {
+				return err
+			}
+			if err := c.verifyMerkleTreeProofs(step, leafIndexes, elementMatrix(circuit.MerklePaths.Leaves[r-1]), circuit.MerklePaths.LeafSiblingHashes[r-1], circuit.MerklePaths.AuthPaths[r-1], rootHashList[r-1]); err != nil {
+				return err
+			}
+			if err := nativeIsSubset(fmt.Sprintf("IsSubset (round %d)", r), stirChallengeIndexes, leafIndexes, circuit.Universal); err != nil {
+				return err
+			}
+		}
+		stirChallengesPoints[r] = make([]fr.Element, len(leafIndexes))
+		for i, index := range leafIndexes {
+			stirChallengesPoints[r][i] = native.Exponent(expDomainGenerator, index)
+		}
+
+		if err := c.runPoW(fmt.Sprintf("RunPoW (round %d)", r), circuit.PowBits[r]); err != nil {
+			return err
+		}
+
+		combinationRandomness[r], err = c.generateCombinationRandomness(fmt.Sprintf("GenerateCombinationRandomness (round %d)", r), len(roundOODAnswers)+len(computedFold))
+		if err != nil {
+			return err
+		}
+
+		if circuit.Universal {
+			nativeMaskPaddedOpenings(combinationRandomness[r][len(roundOODAnswers):], leafIndexes)
+		}
+
+		shift := native.DotProduct(append(roundOODAnswers, computedFold...), combinationRandomness[r])
+		lastEval.Add(&lastEval, &shift)
+
+		var roundFoldingRandomness []fr.Element
+		roundFoldingRandomness, lastEval, err = c.runSumcheckRounds(fmt.Sprintf("runSumcheckRounds (round %d)", r), lastEval, circuit.FoldingFactorArray[r])
+		if err != nil {
+			return err
+		}
+
+		computedFold = nativeFoldLeaves(circuit, elementMatrix(circuit.MerklePaths.Leaves[r]), uint64s(circuit.MerklePaths.LeafIndexes[r]), roundFoldingRandomness, r+1)
+		totalFoldingRandomness = append(totalFoldingRandomness, roundFoldingRandomness...)
+
+		domainSize /= 2
+		expDomainGenerator.Square(&expDomainGenerator)
+	}
+
+	finalCoefficients := make([]fr.Element, 1<<circuit.FinalSumcheckRounds)
+	if err := c.arthur.FillNextScalars(finalCoefficients); err != nil {
+		return rejectErr("generateFinalCoefficientsAndRandomnessPoints", err)
+	}
+	finalIndexes, err := c.getStirChallenges(circuit.FinalQueries, domainSize, len(circuit.FoldingFactorArray)-1)
+	if err != nil {
+		return err
+	}
+	finalLeafIndexes := uint64s(circuit.MerklePaths.LeafIndexes[len(circuit.MerklePaths.LeafIndexes)-1])
+	if err := nativeIsSubset("GenerateStirChallengePoints", finalIndexes, finalLeafIndexes, circuit.Universal); err != nil {
+		return err
+	}
+	if err := c.runPoW("RunPoW (final)", circuit.FinalPowBits); err != nil {
+		return err
+	}
+
+	for i, index := range finalLeafIndexes {
+		point := native.Exponent(expDomainGenerator, index)
+		evaluation := native.UnivarPoly(finalCoefficients, point)
+		if !evaluation.Equal(&computedFold[i]) {
+			return reject("final folds", "leaf %d folds to %s but the final polynomial evaluates to %s", index, computedFold[i].String(), evaluation.String())
+		}
+	}
+
+	finalSumcheckRandomness, lastEval, err := c.runSumcheckRounds("runSumcheckRounds (final)", lastEval, circuit.FinalSumcheckRounds)
+	if err != nil {
+		return err
+	}
+	totalFoldingRandomness = append(totalFoldingRandomness, finalSumcheckRandomness...)
+
+	if err := c.runPoW("RunPoW (final folding)", circuit.FinalFoldingPowBits); err != nil {
+		return err
+	}
+
+	evaluationOfWPoly := c.computeWPoly(initialOODQueries, initialCombinationRandomness, oodPoints, stirChallengesPoints, combinationRandomness, spRand, totalFoldingRandomness)
+	finalEval := native.MultivarPoly(finalCoefficients, finalSumcheckRandomness)
+	finalEval.Mul(&finalEval, &evaluationOfWPoly)
+	if !lastEval.Equal(&finalEval) {
+		return reject("ComputeWPoly", "sumcheck claim %s does not match the final evaluation %s", lastEval.String(), finalEval.String())
+	}
+
+	statementEvaluations := elements(circuit.LinearStatementEvaluations)
+	var r1csClaim fr.Element
+	r1csClaim.Mul(&statementEvaluations[0], &statementEvaluations[1])
+	r1csClaim.Sub(&r1csClaim, &statementEvaluations[2])
+	eq := native.EqPolyOutside(spRand, tRand)
+	r1csClaim.Mul(&r1csClaim, &eq)
+	if !savedValForSumcheck.Equal(&r1csClaim) {
+		return reject("SumcheckForR1CSIOP", "final claim %s does not match (a·b - c)·eq(sp_rand, t_rand) = %s", savedValForSumcheck.String(), r1csClaim.String())
+	}
+
+	return nil
+}
+
+func (c *nativeChecker) sumcheckForR1CSIOP() ([]fr.Element, []fr.Element, fr.Element, error) {
+	const step = "SumcheckForR1CSIOP"
+	tRand := make([]fr.Element, c.circuit.LogNumConstraints)
+	if err := c.arthur.FillChallengeScalars(tRand); err != nil {
+		return nil, nil, fr.Element{}, rejectErr(step, err)
+	}
+
+	spRand, savedValForSumcheck, err := c.sumcheck(step, fr.Element{}, c.circuit.LogNumConstraints, 3, utilities.SumcheckCoefficients)
+	if err != nil {
+		return nil, nil, fr.Element{}, err
+	}
+	return tRand, spRand, savedValForSumcheck, nil
+}
+
+func (c *nativeChecker) parseBatchedCommitment() ([][]byte, fr.Element, []fr.Element, [][]fr.Element, error) {
+	const step = "parseBatchedCommitment"
+	rootHashes := make([][]byte, c.circuit.BatchSize)
+	for i := range rootHashes {
+		var err error
+		if rootHashes[i], err = c.merkle.readRoot(c.arthur); err != nil {
+			return nil, fr.Element{}, nil, nil, rejectErr(step, err)
+		}
+	}
+	oodPoints := make([]fr.Element, 1)
+	if err := c.arthur.FillChallengeScalars(oodPoints); err != nil {
+		return nil, fr.Element{}, nil, nil, rejectErr(step, err)
+	}
+	oodAnswers := make([][]fr.Element, c.circuit.BatchSize)
+	for i := range oodAnswers {
+		oodAnswers[i] = make([]fr.Element, 1)
+		if err := c.arthur.FillNextScalars(oodAnswers[i]); err != nil {
+			return nil, fr.Element{}, nil, nil, rejectErr(step, err)
+		}
+	}
+	batchingRandomness := make([]fr.Element, 1)
+	if err := c.arthur.FillChallengeScalars(batchingRandomness); err != nil {
+		return nil, fr.Element{}, nil, nil, rejectErr(step, err)
+	}
+	return rootHashes, batchingRandomness[0], oodPoints, oodAnswers, nil
+}
+
+func (c *nativeChecker) initialSumcheck(initialOODAnswers []fr.Element) ([]fr.Element, fr.Element, []fr.Element, error) {
+	statementEvaluations := elements(c.circuit.LinearStatementEvaluations)
+	combinationRandomness, err := c.generateCombinationRandomness("initialSumcheck", len(initialOODAnswers)+len(statementEvaluations))
+	if err != nil {
+		return nil, fr.Element{}, nil, err
+	}
+	lastEval := native.DotProduct(combinationRandomness, append(append([]fr.Element{}, initialOODAnswers...), statementEvaluations...))
+	foldingRandomness, lastEval, err := c.runSumcheckRounds("initialSumcheck", lastEval, c.circuit.FoldingFactorArray[0])
+	if err != nil {
+		return nil, fr.Element{}, nil, err
+	}
+	return combinationRandomness, lastEval, foldingRandomness, nil
+}
+
+func (c *nativeChecker) generateCombinationRandomness(step string, length int) ([]fr.Element, error) {
+	gen := make([]fr.Element, 1)
+	if err := c.arthur.FillChallengeScalars(gen); err != nil {
+		return nil, rejectErr(step, err)
+	}
+	return native.ExpandRandomness(gen[0], length), nil
+}
+
+func (c *nativeChecker) runSumcheckRounds(step string, lastEval fr.Element, foldingFactor int) ([]fr.Element, fr.Element, error) {
+	return c.sumcheck(step, lastEval, foldingFactor, 2, utilities.SumcheckEvaluations)
+}
+
+// sumcheck mirrors utilities.Sumcheck.
+func (c *nativeChecker) sumcheck(step string, claim fr.Element, rounds int, degree int, encoding utilities.SumcheckEncoding) ([]fr.Element, fr.Element, error) {
+	randomness := make([]fr.Element, rounds)
+	for i := range rounds {
+		polynomial := make([]fr.Element, degree+1)
+		if err := c.arthur.FillNextScalars(polynomial); err != nil {
+			return nil, fr.Element{}, rejectErr(step, err)
+		}
+		if err := c.arthur.FillChallengeScalars(randomness[i : i+1]); err != nil {
+			return nil, fr.Element{}, rejectErr(step, err)
+		}
+
+		var sum fr.Element
+		if encoding == utilities.SumcheckEvaluations {
+			sum.Add(&polynomial[0], &polynomial[1])
+		} else {
+			sum.Double(&polynomial[0])
+			for _, coefficient := range polynomial[1:] {
+				sum.Add(&sum, &coefficient)
+			}
+		}
+		if !sum.Equal(&claim) {
+			return nil, fr.Element{}, reject(step, "round %d: sum over {0,1} is %s, expected %s", i, sum.String(), claim.String())
+		}
+
+		if encoding == utilities.SumcheckEvaluations {
+			claim = native.EvaluateFromEvaluationList(polynomial, randomness[i])
+		} else {
+			claim = native.UnivarPoly(polynomial, randomness[i])
+		}
+	}
+	return randomness, claim, nil
+}
+
+func (c *nativeChecker) getStirChallenges(numQueries int, domainSize int, roundIndex int) ([]uint64, error) {
+	foldedDomainSize := domainSize / (1 << c.circuit.FoldingFactorArray[roundIndex])
+	domainSizeBytes := (bits.Len(uint(foldedDomainSize*2-1)) - 1 + 7) / 8
+
+	stirQueries := make([]byte, domainSizeBytes*numQueries)
+	if err := c.arthur.FillChallengeBytes(stirQueries); err != nil {
+		return nil, rejectErr(fmt.Sprintf("GetStirChallenges (round %d)", roundIndex), err)
+	}
+
+	bitLength := bits.Len(uint(foldedDomainSize)) - 1
+	mask := uint64(1)<<bitLength - 1
+	indexes := make([]uint64, numQueries)
+	for i := range numQueries {
+		value := uint64(0)
+		for j := range domainSizeBytes {
+			value = value<<8 | uint64(stirQueries[j+i*domainSizeBytes])
+		}
+		indexes[i] = value & mask
+	}
+	return indexes, nil
+}
+
+func (c *nativeChecker) runPoW(step string, difficulty int) error {
+	if difficulty <= 0 {
+		return nil
+	}
+	challenge := make([]byte, 32)
+	if err := c.arthur.FillChallengeBytes(challenge); err != nil {
+		return rejectErr(step, err)
+	}
+	nonce := make([]byte, 8)
+	if err := c.arthur.FillNextBytes(nonce); err != nil {
+		return rejectErr(step, err)
+	}
+	hash, err := nativePoWHash(c.circuit.PoWHash, challenge, nonce)
+	if err != nil {
+		return rejectErr(step, err)
+	}
+	bound, err := powBound(c.circuit.PoWHash, difficulty, c.circuit.PoWMode)
+	if err != nil {
+		return rejectErr(step, err)
+	}
+	if hash.Cmp(bound) > 0 {
+		return reject(step, "hash %s is above the %d bit bound", hash.String(), difficulty)
+	}
+	return nil
+}
+
+func (c *nativeChecker) computeWPoly(
+	initialOODQueries []fr.Element,
+	initialCombinationRandomness []fr.Element,
+	oodPoints [][]fr.Element,
+	stirChallengesPoints [][]fr.Element,
+	combinationRandomness [][]fr.Element,
+	spRand []fr.Element,
+	totalFoldingRandomness []fr.Element,
+) fr.Element {
+	foldingRandomnessReversed := native.Reverse(totalFoldingRandomness)
+	numberVars := c.circuit.MVParamsNumberOfVariables
+
+	var value fr.Element
+	for j := range initialOODQueries {
+		eq := native.EqPolyOutside(native.ExpandFromUnivariate(initialOODQueries[j], numberVars), foldingRandomnessReversed)
+		eq.Mul(&eq, &initialCombinationRandomness[j])
+		value.Add(&value, &eq)
+	}
+
+	matrixExtensionEvals := nativeEvaluateR1CSMatrixExtension(c.matrices, spRand, foldingRandomnessReversed)
+	if len(c.circuit.PublicInputs) > 0 {
+		matrixExtensionEvals = append(matrixExtensionEvals, nativePublicInputWeight(initialOODQueries[0], len(c.circuit.PublicInputs)+1, foldingRandomnessReversed))
+	}
+	for j := range c.circuit.LinearStatementValuesAtPoints {
+		var term fr.Element
+		term.Mul(&initialCombinationRandomness[len(initialOODQueries)+j], &matrixExtensionEvals[j])
+		value.Add(&value, &term)
+	}
+
+	for r := range oodPoints {
+		numberVars -= c.circuit.FoldingFactorArray[r]
+		points := append(append([]fr.Element{}, oodPoints[r]...), stirChallengesPoints[r]...)
+		for i := range points {
+			eq := native.EqPolyOutside(native.ExpandFromUnivariate(points[i], numberVars), foldingRandomnessReversed[0:numberVars])
+			eq.Mul(&eq, &combinationRandomness[r][i])
+			value.Add(&value, &eq)
+		}
+	}
+	return value
+}
+
+func nativeEvaluateR1CSMatrixExtension(matrices [][]MatrixCell, rowRand []fr.Element, colRand []fr.Element) []fr.Element {
+	rowEval := native.EqOverBooleanHypercube(rowRand)
+	colEval := native.EqOverBooleanHypercube(colRand)
+
+	evaluate := func(matrix []MatrixCell) fr.Element {
+		var ans fr.Element
+		for _, cell := range matrix {
+			var term, value fr.Element
+			value.SetBigInt(cell.value)
+			term.Mul(&rowEval[cell.row], &colEval[cell.column])
+			term.Mul(&term, &value)
+			ans.Add(&ans, &term)
+		}
+		return ans
+	}
+	ans := make([]fr.Element, len(matrices))
+	for i := range matrices {
+		ans[i] = evaluate(matrices[i])
+	}
+	return ans
+}
+
+// checkShapes mirrors defineShapes, checking the proof with the verifier of
+// the selected shape only.
+func checkShapes(circuit *Circuit) error {
+	for k, selector := range circuit.ShapeSelector {
+		if selected := toElement(selector); !selected.IsOne() {
+			continue
+		}
+		shape, err := circuit.shape(k)
+		if err != nil {
+			return rejectErr("defineShapes", err)
+		}
+		for i, b := range bytesOf(circuit.transcript()[shape.TranscriptLen:]) {
+			if b != 0 {
+				return reject("defineShapes", "transcript byte %d past the %d bytes of shape %d is %d", shape.TranscriptLen+i, shape.TranscriptLen, k, b)
+			}
+		}
+		return checkCircuit(shape)
+	}
+	return reject("defineShapes", "none of the %d shapes is selected", len(circuit.Shapes))
+}
+
+// nativeMatrices returns the R1CS matrices, reading the padded inputs of the
+// universal circuit back into cells. The circuit looks the entries up in the
+// eq tables, so an entry outside of them fails there.
+func nativeMatrices(circuit *Circuit) ([][]MatrixCell, error) {
+	if !circuit.Universal {
+		return [][]MatrixCell{circuit.MatrixA, circuit.MatrixB, circuit.MatrixC}, nil
+	}
+	rows := uint64(1) << circuit.LogNumConstraints
+	columns := uint64(1) << circuit.MVParamsNumberOfVariables
+	matrices := make([][]MatrixCell, 3)
+	for m, entries := range circuit.matrixEntries() {
+		matrices[m] = make([]MatrixCell, len(entries))
+		for i, entry := range entries {
+			row, column, value := toElement(entry.Row), toElement(entry.Column), toElement(entry.Value)
+			if !row.IsUint64() || row.Uint64() >= rows || !column.IsUint64() || column.Uint64() >= columns {
+				return nil, reject("evaluateUniversalMatrixExtension", "matrix %c entry %d at (%s, %s) is outside of the %dx%d matrix", "ABC"[m], i, row.String(), column.String(), rows, columns)
+			}
+			matrices[m][i] = MatrixCell{row: int(row.Uint64()), column: int(column.Uint64()), value: value.BigInt(new(big.Int))}
+		}
+	}
+	return matrices, nil
+}
+
+// nativeMaskPaddedOpenings mirrors maskPaddedOpenings. nativeIsSubset has
+// checked that the repeats form a tail.
+func nativeMaskPaddedOpenings(randomness []fr.Element, leafIndexes []uint64) {
+	for i := 1; i < len(leafIndexes); i++ {
+		if leafIndexes[i] == leafIndexes[i-1] {
+			randomness[i].SetZero()
+		}
+	}
+}
+
+func nativeBindPublicInputs(circuit *Circuit, gamma fr.Element) error {
+	var expected, power fr.Element
+	expected.SetOne()
+	power.SetOne()
+	for _, publicInput := range elements(circuit.PublicInputs) {
+		power.Mul(&power, &gamma)
+		publicInput.Mul(&publicInput, &power)
+		expected.Add(&expected, &publicInput)
+	}
+	claimed := toElement(circuit.LinearStatementEvaluations[len(circuit.LinearStatementEvaluations)-1])
+	if !expected.Equal(&claimed) {
+		return reject("bindPublicInputs", "public inputs evaluate to %s but the witness statement claims %s", expected.String(), claimed.String())
+	}
+	return nil
+}
+
+func nativePublicInputWeight(gamma fr.Element, n int, r []fr.Element) fr.Element {
+	var ans, power fr.Element
+	power.SetOne()
+	for i := range n {
+		indexBits := make([]fr.Element, len(r))
+		for j := range r {
+			indexBits[j].SetUint64(uint64(i>>(len(r)-1-j)) & 1)
+		}
+		eq := native.EqPolyOutside(indexBits, r)
+		eq.Mul(&eq, &power)
+		ans.Add(&ans, &eq)
+		power.Mul(&power, &gamma)
+	}
+	return ans
+}
+
+// verifyMerkleTreeProofs mirrors VerifyMerkleTreeProofs.
+func (c *nativeChecker) verifyMerkleTreeProofs(step string, leafIndexes []uint64, leaves [][]fr.Element, leafSiblingHashes [][]uints.U8, authPaths [][][]uints.U8, rootHash []byte) error {
+	arity := c.circuit.MerkleArity
+	digitBits := bits.TrailingZeros(uint(arity))
+	for i := range leaves {
+		treeHeight := len(authPaths[i]) + 1
+		if leafIndexes[i] >= 1<<(treeHeight*digitBits) {
+			return reject(step, "leaf index %d does not fit a tree of height %d and arity %d", leafIndexes[i], treeHeight, arity)
+		}
+		currentHash, err := c.merkle.hashLeaf(leaves[i])
+		if err != nil {
+			return rejectErr(step, err)
+		}
+		for level := range treeHeight {
+			siblingBytes := bytesOf(leafSiblingHashes[i])
+			if level > 0 {
+				siblingBytes = bytesOf(authPaths[i][level-1])
+			}
+			digit := int(leafIndexes[i] >> (level * digitBits) & uint64(arity-1))
+			children := make([][]byte, 0, arity)
+			for k := range arity - 1 {
+				if k == digit {
+					children = append(children, currentHash)
+				}
+				children = append(children, c.merkle.digest(siblingBytes[k*len(siblingBytes)/(arity-1):(k+1)*len(siblingBytes)/(arity-1)]))
+			}
+			if digit == arity-1 {
+				children = append(children, currentHash)
+			}
+			currentHash = c.merkle.compress(children...)
+		}
+
+		if !bytes.Equal(currentHash, rootHash) {
+			return reject(step, "path of leaf %d hashes to %x, expected root %x", leafIndexes[i], currentHash, rootHash)
+		}
+	}
+	return nil
+}
+
+// checkLayout mirrors the leaf indexes VerifyMerkleMultiProof compiles in.
+// Hashing the shared nodes once accepts exactly the paths verified one by
+// one, so only the indexes are left to check.
+func checkLayout(step string, paths MerklePaths, r int, indexes []uint64) error {
+	if paths.Layout == nil {
+		return nil
+	}
+	if !slices.Equal(paths.Layout[r], indexes) {
+		return reject(step, "leaf indexes %v differ from the %v the circuit is compiled for", indexes, paths.Layout[r])
+	}
+	return nil
+}
+
+// nativeIsSubset mirrors IsSubset.
+func nativeIsSubset(step string, indexes []uint64, merkleIndexes []uint64, padded bool) error {
+	tail := false
+	for j := 1; j < len(merkleIndexes); j++ {
+		repeated := merkleIndexes[j] == merkleIndexes[j-1]
+		if merkleIndexes[j] < merkleIndexes[j-1] || repeated && !padded {
+			return reject(step, "leaf indexes %v are not sorted and distinct", merkleIndexes)
+		}
+		if tail && !repeated {
+			return reject(step, "leaf indexes %v repeat before the padding", merkleIndexes)
+		}
+		tail = repeated
+	}
+	opened := make(map[uint64]bool, len(merkleIndexes))
+	for _, index := range merkleIndexes {
+		opened[index] = true
+	}
+	for _, index := range indexes {
+		if !opened[index] {
+			return reject(step, "challenge index %d was not opened", index)
+		}
+	}
+	return nil
+}
+
+func nativeOODAnswers(answers [][]fr.Element, randomness fr.Element) []fr.Element {
+	if len(answers) == 0 {
+		return nil
+	}
+	result := append([]fr.Element{}, answers[0]...)
+	var multiplier fr.Element
+	multiplier.SetOne()
+	for i := 1; i < len(answers); i++ {
+		multiplier.Mul(&multiplier, &randomness)
+		for j := range answers[i] {
+			var term fr.Element
+			term.Mul(&answers[i][j], &multiplier)
+			result[j].Add(&result[j], &term)
+		}
+	}
+	return result
+}
+
+func nativeCombineFirstRoundLeaves(firstRoundPath [][][]fr.Element, combinationRandomness fr.Element) [][]fr.Element {
+	combined := make([][]fr.Element, len(firstRoundPath[0]))
+	for j := range combined {
+		combined[j] = append([]fr.Element{}, firstRoundPath[0][j]...)
+	}
+	multiplier := combinationRandomness
+	for i := 1; i < len(firstRoundPath); i++ {
+		for j := range firstRoundPath[i] {
+			for k := range firstRoundPath[i][j] {
+				var term fr.Element
+				term.Mul(&multiplier, &firstRoundPath[i][j][k])
+				combined[j][k].Add(&combined[j][k], &term)
+			}
+		}
+		multiplier.Mul(&multiplier, &combinationRandomness)
+	}
+	return combined
+}
+
+func nativeComputeFold(leaves [][]fr.Element, foldingRandomness []fr.Element) []fr.Element {
+	computedFold := make([]fr.Element, len(leaves))
+	for j := range leaves {
+		computedFold[j] = native.MultivarPoly(leaves[j], foldingRandomness)
+	}
+	return computedFold
+}
+
+// nativeFoldLeaves mirrors foldLeaves.
+func nativeFoldLeaves(circuit *Circuit, leaves [][]fr.Element, leafIndexes []uint64, foldingRandomness []fr.Element, round int) []fr.Element {
+	if circuit.FoldOptimisation {
+		return nativeComputeFold(leaves, foldingRandomness)
+	}
+	domainGenInv := toElement(circuit.StartingDomainBackingDomainGenerator)
+	domainGenInv.Inverse(&domainGenInv)
+	for range round {
+		domainGenInv.Square(&domainGenInv)
+	}
+	cosetGenInv := domainGenInv
+	for size := circuit.DomainSize >> round; size > 1<<len(foldingRandomness); size /= 2 {
+		cosetGenInv.Square(&cosetGenInv)
+	}
+
+	computedFold := make([]fr.Element, len(leaves))
+	for j := range leaves {
+		computedFold[j] = nativeComputeFoldNaive(leaves[j], foldingRandomness, native.Exponent(domainGenInv, leafIndexes[j]), cosetGenInv)
+	}
+	return computedFold
+}
+
+// nativeComputeFoldNaive mirrors computeFoldNaive.
+func nativeComputeFoldNaive(answers []fr.Element, foldingRandomness []fr.Element, cosetOffsetInv fr.Element, cosetGenInv fr.Element) fr.Element {
+	var twoInv fr.Element
+	twoInv.SetUint64(2).Inverse(&twoInv)
+	for _, r := range foldingRandomness {
+		half := len(answers) / 2
+		folded := make([]fr.Element, half)
+		pointInv := cosetOffsetInv
+		for i := range half {
+			var left, right fr.Element
+			left.Add(&answers[i], &answers[i+half])
+			right.Sub(&answers[i], &answers[i+half]).Mul(&right, &pointInv)
+			right.Mul(&right, &r)
+			folded[i].Add(&left, &right).Mul(&folded[i], &twoInv)
+			pointInv.Mul(&pointInv, &cosetGenInv)
+		}
+		answers = folded
+		cosetOffsetInv.Square(&cosetOffsetInv)
+		cosetGenInv.Square(&cosetGenInv)
+	}
+	return answers[0]
+}
+
+// toElement converts a witness value set by buildCircuits. Those are always
+// integers, so a failing conversion is a programming error.
+func toElement(v frontend.Variable) fr.Element {
+	var e fr.Element
+	if _, err := e.SetInterface(v); err != nil {
+		panic(err)
+	}
+	return e
+}
+
+func elements(vs []frontend.Variable) []fr.Element {
+	res := make([]fr.Element, len(vs))
+	for i := range vs {
+		res[i] = toElement(vs[i])
+	}
+	return res
+}
+
+func elementMatrix(vs [][]frontend.Variable) [][]fr.Element {
+	res := make([][]fr.Element, len(vs))
+	for i := range vs {
+		res[i] = elements(vs[i])
+	}
+	return res
+}
+
+func bytesOf(us []uints.U8) []byte {
+	res := make([]byte, len(us))
+	for i := range us {
+		res[i] = us[i].Val.(uint8)
+	}
+	return res
+}
+
+func uint64s(us []uints.U64) []uint64 {
+	res := make([]uint64, len(us))
+	for i := range us {
+		for j := range us[i] {
+			res[i] |= uint64(us[i][j].Val.(uint8)) << (8 * j)
+		}
+	}
+	return res
+}
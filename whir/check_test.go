@@ -0,0 +1,62 @@
+package whir
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+)
+
+// A proof the test prover makes passes Check, and one whose opened leaf or
+// statement evaluation is changed afterwards fails it at the step that reads
+// them, and fails the circuit too.
+func TestCheckRejectsTamperedProof(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		tamper func(proof *ProofObject, cfg *Config)
+		step   string
+	}{
+		{"valid", func(*ProofObject, *Config) {}, ""},
+		{"first round leaf", func(proof *ProofObject, _ *Config) {
+			proof.FirstRoundPaths[0].B[0][0].Limbs[0]++
+		}, "ValidateFirstRound (batch 0)"},
+		{"round leaf", func(proof *ProofObject, _ *Config) {
+			proof.MerklePaths[0].B[0][0].Limbs[0]++
+		}, "VerifyMerkleTreeProofs (round 1)"},
+		{"statement evaluation", func(_ *ProofObject, cfg *Config) {
+			var e fr.Element
+			if _, err := e.SetString(cfg.StatementEvaluations[0]); err != nil {
+				t.Fatal(err)
+			}
+			e.Add(&e, new(fr.Element).SetOne())
+			cfg.StatementEvaluations[0] = e.String()
+		}, "initialSumcheck"},
+	} {
+		proof, cfg, r1cs, interner := proveTestProgram(t, chainProgram, false)
+		tc.tamper(&proof, &cfg)
+		v, err := NewVerifier(proof, cfg, r1cs, interner)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+
+		err = v.Check()
+		if tc.step == "" {
+			if err != nil {
+				t.Errorf("%s: %v", tc.name, err)
+			}
+			if err := isSolved(v); err != nil {
+				t.Errorf("%s: %v", tc.name, err)
+			}
+			continue
+		}
+		var checkErr *CheckError
+		if !errors.As(err, &checkErr) {
+			t.Errorf("%s: Check returns %v, expected a rejection at %s", tc.name, err, tc.step)
+		} else if checkErr.Step != tc.step {
+			t.Errorf("%s: Check rejects at %s, expected %s", tc.name, checkErr.Step, tc.step)
+		}
+		if err := isSolved(v); err == nil {
+			t.Errorf("%s: the circuit accepts the tampered proof", tc.name)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package whir
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Stage names a step of the wrapping pipeline.
+type Stage string
+
+const (
+	StageParse   Stage = "parse"
+	StageCheck   Stage = "check"
+	StageCompile Stage = "compile"
+	StageSetup   Stage = "setup"
+	StageWitness Stage = "witness"
+	StageProve   Stage = "prove"
+	StageVerify  Stage = "verify"
+	StageExport  Stage = "export"
+)
+
+// VerifierError is returned by every step of the pipeline and records which
+// step failed. A rejected proof is reported with StageVerify.
+type VerifierError struct {
+	Stage Stage
+	Err   error
+}
+
+func (e *VerifierError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Stage, e.Err)
+}
+
+func (e *VerifierError) Unwrap() error {
+	return e.Err
+}
+
+// WithStage attributes err to stage unless it already carries a stage.
+func WithStage(stage Stage, err error) error {
+	if err == nil {
+		return nil
+	}
+	var verr *VerifierError
+	if errors.As(err, &verr) {
+		return err
+	}
+	return &VerifierError{Stage: stage, Err: err}
+}
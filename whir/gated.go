@@ -0,0 +1,71 @@
+package whir
+
+import (
+	"fmt"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// gatedAPI enforces the assertions made through it only when active is one.
+// The universal circuit verifies the proof once for every shape it admits and
+// selects one of them, so the verifiers of the other shapes run on whatever
+// the witness holds and must not constrain it.
+//
+// Everything but the assertions is computed as usual, which keeps the solver
+// going on such a witness: the range checks, lookups and hints underneath
+// only ever see values computed by the solver itself, except for ToBinary,
+// which decomposes zero instead of its input when inactive.
+type gatedAPI struct {
+	frontend.API
+	active frontend.Variable
+}
+
+func (g gatedAPI) AssertIsEqual(i1, i2 frontend.Variable) {
+	g.API.AssertIsEqual(g.API.Mul(g.active, g.API.Sub(i1, i2)), 0)
+}
+
+func (g gatedAPI) AssertIsDifferent(i1, i2 frontend.Variable) {
+	g.API.AssertIsDifferent(g.API.Select(g.active, g.API.Sub(i1, i2), 1), 0)
+}
+
+func (g gatedAPI) AssertIsBoolean(i1 frontend.Variable) {
+	g.API.AssertIsBoolean(g.API.Select(g.active, i1, 0))
+}
+
+func (g gatedAPI) AssertIsCrumb(i1 frontend.Variable) {
+	g.API.AssertIsCrumb(g.API.Select(g.active, i1, 0))
+}
+
+func (g gatedAPI) AssertIsLessOrEqual(v frontend.Variable, bound frontend.Variable) {
+	g.API.AssertIsLessOrEqual(g.API.Select(g.active, v, 0), bound)
+}
+
+func (g gatedAPI) ToBinary(i1 frontend.Variable, n ...int) []frontend.Variable {
+	return g.API.ToBinary(g.API.Select(g.active, i1, 0), n...)
+}
+
+// Commit, SetKeyValue and GetKeyValue forward to the builder, so that the
+// range checker and the multicommitter of the standard library are shared
+// with the rest of the circuit.
+func (g gatedAPI) Commit(v ...frontend.Variable) (frontend.Variable, error) {
+	committer, ok := g.API.(frontend.Committer)
+	if !ok {
+		return nil, fmt.Errorf("builder does not implement frontend.Committer")
+	}
+	return committer.Commit(v...)
+}
+
+func (g gatedAPI) SetKeyValue(key, value any) {
+	g.API.(keyValueStore).SetKeyValue(key, value)
+}
+
+func (g gatedAPI) GetKeyValue(key any) any {
+	return g.API.(keyValueStore).GetKeyValue(key)
+}
+
+// keyValueStore is the cache the gnark builders keep for the standard
+// library.
+type keyValueStore interface {
+	SetKeyValue(key, value any)
+	GetKeyValue(key any) any
+}
@@ -0,0 +1,548 @@
+package whir
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"reilabs/whir-verifier-circuit/keccakSponge"
+	"reilabs/whir-verifier-circuit/native"
+	"reilabs/whir-verifier-circuit/poseidon2"
+	"reilabs/whir-verifier-circuit/typeConverters"
+	"reilabs/whir-verifier-circuit/utilities"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/hash/mimc"
+	"github.com/consensys/gnark/std/math/uints"
+	gnark_nimue "github.com/reilabs/gnark-nimue"
+	skyscraper "github.com/reilabs/gnark-skyscraper"
+)
+
+// Hash names a hash function the WHIR prover can be configured with, for its
+// Merkle trees, its Fiat-Shamir transcript or its proof of work.
+type Hash string
+
+const (
+	// HashSkyscraper is the default for all three.
+	HashSkyscraper Hash = "skyscraper"
+	// HashKeccak is Keccak-256, as WHIR's Keccak configs use. Its digests are
+	// 32 bytes rather than field elements.
+	HashKeccak Hash = "keccak"
+	// HashMiMC is the MiMC hash of gnark's std. It has no permutation, so its
+	// transcript sponge permutes with the hash, see mimcDuplex.
+	HashMiMC Hash = "mimc"
+	// HashPoseidon2 is Poseidon2 over BN254 with a width of 3. Nodes are the
+	// truncated permutation of their children and leaves are absorbed into a
+	// sponge of rate 2.
+	HashPoseidon2 Hash = "poseidon2"
+)
+
+func ParseHash(s string) (Hash, error) {
+	switch hash := Hash(s); hash {
+	case HashSkyscraper, HashKeccak, HashMiMC, HashPoseidon2:
+		return hash, nil
+	}
+	return "", fmt.Errorf("unknown hash %q, expected skyscraper, keccak, mimc or poseidon2", s)
+}
+
+// WithMerkleHash selects the hash of the Merkle trees, overriding the
+// merkle_hash of the params.
+func WithMerkleHash(hash Hash) Option {
+	return func(s *settings) {
+		s.MerkleHash = hash
+	}
+}
+
+// resolveHash returns the hash picked by the option, or else by the params,
+// Skyscraper when neither picks one.
+func resolveHash(option Hash, params string) (Hash, error) {
+	if option != "" {
+		return option, nil
+	}
+	if params == "" {
+		return HashSkyscraper, nil
+	}
+	return ParseHash(params)
+}
+
+// resolveHashes sets the hashes of the circuit from the settings and the
+// params, and checks that they can verify the proof.
+func resolveHashes(circuit *Circuit, cfg Config, s settings) error {
+	var err error
+	if circuit.MerkleHash, err = resolveHash(s.MerkleHash, cfg.MerkleHash); err != nil {
+		return err
+	}
+	if circuit.TranscriptHash, err = resolveHash("", cfg.TranscriptHash); err != nil {
+		return err
+	}
+	if circuit.PoWHash, err = resolveHash("", cfg.PoWHash); err != nil {
+		return err
+	}
+	if cfg.LeafHash == "" {
+		circuit.LeafHash = defaultLeafHash(circuit.MerkleHash)
+	} else if circuit.LeafHash, err = ParseLeafHash(cfg.LeafHash); err != nil {
+		return err
+	}
+	if circuit.MerkleHash == HashKeccak && circuit.LeafHash != LeafKeccak && circuit.LeafHash != LeafSingle {
+		return fmt.Errorf("keccak merkle trees cannot hash leaves with %s", circuit.LeafHash)
+	}
+	return nil
+}
+
+// Digest is a Merkle tree node: a single field element for the algebraic
+// hashes and 32 bytes for Keccak.
+type Digest []frontend.Variable
+
+// MerkleHasher hashes the leaves and the nodes of a Merkle tree.
+type MerkleHasher interface {
+	// ReadRoot reads the root of a tree the prover commits to.
+	ReadRoot(arthur gnark_nimue.Arthur) (Digest, error)
+	// Digest reads a node sent in an authentication path.
+	Digest(bytes []uints.U8) Digest
+	HashLeaf(leaf []frontend.Variable) (Digest, error)
+	// Compress hashes the children of a node, from left to right.
+	Compress(children ...Digest) Digest
+}
+
+// usesSkyscraper tells whether the circuit hashes with Skyscraper anywhere.
+// Its S-box lookup table fails to compile when nothing looks it up.
+func usesSkyscraper(circuit *Circuit) bool {
+	for _, hash := range []Hash{circuit.MerkleHash, circuit.TranscriptHash, circuit.PoWHash} {
+		if hash == HashSkyscraper || hash == "" {
+			return true
+		}
+	}
+	return circuit.TranscriptMode == TranscriptSkyscraper || circuit.CommitMatrices
+}
+
+// hashes are the hash functions the proof is verified with.
+type hashes struct {
+	merkle MerkleHasher
+	pow    utilities.PoWHasher
+}
+
+func newHashes(api frontend.API, uapi *uints.BinaryField[uints.U64], sc *skyscraper.Skyscraper, circuit *Circuit) (hashes, error) {
+	merkle, err := newMerkleHasher(api, uapi, sc, circuit.MerkleHash, circuit.LeafHash)
+	if err != nil {
+		return hashes{}, err
+	}
+	var pow utilities.PoWHasher
+	switch circuit.PoWHash {
+	case HashSkyscraper, "":
+		pow = utilities.FieldPoW{API: api, Hash: sc}
+	case HashKeccak:
+		pow = utilities.KeccakPoW{API: api, UAPI: uapi}
+	case HashMiMC:
+		h, err := newMiMC(api)
+		if err != nil {
+			return hashes{}, err
+		}
+		pow = utilities.FieldPoW{API: api, Hash: h}
+	case HashPoseidon2:
+		pow = utilities.FieldPoW{API: api, Hash: poseidon2.NewPoseidon2(api)}
+	default:
+		return hashes{}, fmt.Errorf("unknown proof of work hash %q", circuit.PoWHash)
+	}
+	return hashes{merkle: merkle, pow: pow}, nil
+}
+
+func newMerkleHasher(api frontend.API, uapi *uints.BinaryField[uints.U64], sc *skyscraper.Skyscraper, hash Hash, leaf LeafHash) (MerkleHasher, error) {
+	switch hash {
+	case HashSkyscraper, "":
+		return newFieldMerkleHasher(api, uapi, sc, leaf, func(leaf ...frontend.Variable) frontend.Variable {
+			state := [2]frontend.Variable{0, 0}
+			for _, element := range leaf {
+				state[0] = element
+				sc.Permute(&state)
+			}
+			return state[0]
+		})
+	case HashKeccak:
+		return keccakMerkleHasher{api: api, uapi: uapi, leaf: leaf}, nil
+	case HashMiMC:
+		h, err := newMiMC(api)
+		if err != nil {
+			return nil, err
+		}
+		return newFieldMerkleHasher(api, uapi, h, leaf, h.Sum)
+	case HashPoseidon2:
+		p := poseidon2.NewPoseidon2(api)
+		return newFieldMerkleHasher(api, uapi, p, leaf, p.HashLeaf)
+	}
+	return nil, fmt.Errorf("unknown merkle hash %q", hash)
+}
+
+// newArthur returns the transcript of the circuit replayed with the duplex
+// sponge of its TranscriptHash.
+func newArthur(api frontend.API, sc *skyscraper.Skyscraper, circuit *Circuit) (gnark_nimue.Arthur, error) {
+	if circuit.TranscriptHash == HashKeccak {
+		sponge, err := keccakSponge.NewDuplex(api)
+		if err != nil {
+			return nil, err
+		}
+		return gnark_nimue.NewByteArthur(api, circuit.IO, circuit.transcript(), sponge)
+	}
+	sponge, err := newTranscriptSponge(api, sc, circuit.TranscriptHash)
+	if err != nil {
+		return nil, err
+	}
+	return newFieldArthur(api, circuit.IO, circuit.transcript(), sponge)
+}
+
+// fieldMerkleHasher is the MerkleHasher of a hash over field elements,
+// whose nodes are sent as 32 little-endian bytes.
+type fieldMerkleHasher struct {
+	api      frontend.API
+	hash     utilities.Compressor
+	leaf     LeafHash
+	hashLeaf func(leaf ...frontend.Variable) frontend.Variable
+}
+
+// newFieldMerkleHasher returns the fieldMerkleHasher hashing its leaves with
+// the given scheme, where sponge absorbs a leaf into the sponge of the hash.
+func newFieldMerkleHasher(api frontend.API, uapi *uints.BinaryField[uints.U64], hash utilities.Compressor, leaf LeafHash, sponge func(leaf ...frontend.Variable) frontend.Variable) (MerkleHasher, error) {
+	h := fieldMerkleHasher{api: api, hash: hash, leaf: leaf}
+	switch leaf {
+	case LeafChain:
+		h.hashLeaf = func(leaf ...frontend.Variable) frontend.Variable {
+			res := leaf[0]
+			for _, element := range leaf[1:] {
+				res = hash.Compress(res, element)
+			}
+			return res
+		}
+	case LeafSingle:
+		h.hashLeaf = func(leaf ...frontend.Variable) frontend.Variable {
+			return leaf[0]
+		}
+	case LeafSponge:
+		h.hashLeaf = sponge
+	case LeafKeccak:
+		h.hashLeaf = func(leaf ...frontend.Variable) frontend.Variable {
+			return typeConverters.LittleEndianFromUints(api, keccakSponge.Sum256(uapi, serializeLeaf(api, leaf)))
+		}
+	default:
+		return nil, fmt.Errorf("unknown leaf hash %q", leaf)
+	}
+	return h, nil
+}
+
+func (h fieldMerkleHasher) ReadRoot(arthur gnark_nimue.Arthur) (Digest, error) {
+	root := make([]frontend.Variable, 1)
+	if err := arthur.FillNextScalars(root); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+func (h fieldMerkleHasher) Digest(bytes []uints.U8) Digest {
+	return Digest{typeConverters.LittleEndianFromUints(h.api, bytes)}
+}
+
+func (h fieldMerkleHasher) HashLeaf(leaf []frontend.Variable) (Digest, error) {
+	if err := checkLeafSize(h.leaf, len(leaf)); err != nil {
+		return nil, err
+	}
+	return Digest{h.hashLeaf(leaf...)}, nil
+}
+
+// Compress folds the children from left to right with the compression of
+// the hash, which is a single compression in a binary tree.
+func (h fieldMerkleHasher) Compress(children ...Digest) Digest {
+	res := children[0][0]
+	for _, child := range children[1:] {
+		res = h.hash.Compress(res, child[0])
+	}
+	return Digest{res}
+}
+
+// mimcHash hashes from a reset state every time.
+type mimcHash struct {
+	h *mimc.MiMC
+}
+
+func newMiMC(api frontend.API) (mimcHash, error) {
+	h, err := mimc.NewMiMC(api)
+	if err != nil {
+		return mimcHash{}, err
+	}
+	return mimcHash{h: &h}, nil
+}
+
+func (m mimcHash) Sum(data ...frontend.Variable) frontend.Variable {
+	m.h.Reset()
+	m.h.Write(data...)
+	return m.h.Sum()
+}
+
+func (m mimcHash) Compress(left, right frontend.Variable) frontend.Variable {
+	return m.Sum(left, right)
+}
+
+// keccakMerkleHasher hashes as WHIR's Keccak Merkle config does. A leaf
+// hashes to the Keccak-256 of its arkworks serialization, the number of
+// elements as a little-endian uint64 followed by every element as 32
+// little-endian bytes, and a node to the Keccak-256 of its children. A
+// single element leaf is its own 32 bytes instead.
+type keccakMerkleHasher struct {
+	api  frontend.API
+	uapi *uints.BinaryField[uints.U64]
+	leaf LeafHash
+}
+
+func (h keccakMerkleHasher) ReadRoot(arthur gnark_nimue.Arthur) (Digest, error) {
+	root := make([]uints.U8, 32)
+	if err := arthur.FillNextBytes(root); err != nil {
+		return nil, err
+	}
+	return h.Digest(root), nil
+}
+
+func (h keccakMerkleHasher) Digest(bytes []uints.U8) Digest {
+	res := make(Digest, len(bytes))
+	for i := range bytes {
+		res[i] = h.uapi.ByteValueOf(bytes[i].Val).Val
+	}
+	return res
+}
+
+func (h keccakMerkleHasher) HashLeaf(leaf []frontend.Variable) (Digest, error) {
+	if err := checkLeafSize(h.leaf, len(leaf)); err != nil {
+		return nil, err
+	}
+	if h.leaf == LeafSingle {
+		return digestOf(elementBytes(h.api, leaf[0])), nil
+	}
+	return digestOf(keccakSponge.Sum256(h.uapi, serializeLeaf(h.api, leaf))), nil
+}
+
+func (h keccakMerkleHasher) Compress(children ...Digest) Digest {
+	var node []uints.U8
+	for _, child := range children {
+		for _, v := range child {
+			node = append(node, uints.U8{Val: v})
+		}
+	}
+	return digestOf(keccakSponge.Sum256(h.uapi, node))
+}
+
+func digestOf(bytes []uints.U8) Digest {
+	res := make(Digest, len(bytes))
+	for i := range bytes {
+		res[i] = bytes[i].Val
+	}
+	return res
+}
+
+// serializeLeaf returns the arkworks compressed serialization of a slice of
+// field elements.
+func serializeLeaf(api frontend.API, leaf []frontend.Variable) []uints.U8 {
+	res := uints.NewU8Array(binary.LittleEndian.AppendUint64(nil, uint64(len(leaf))))
+	for _, element := range leaf {
+		res = append(res, elementBytes(api, element)...)
+	}
+	return res
+}
+
+// elementBytes returns the 32 little-endian bytes of a field element.
+func elementBytes(api frontend.API, element frontend.Variable) []uints.U8 {
+	// Decomposing into all the bits of the field also checks that the
+	// encoding is canonical.
+	bits := api.ToBinary(element, api.Compiler().FieldBitLen())
+	for len(bits) < 256 {
+		bits = append(bits, 0)
+	}
+	res := make([]uints.U8, 0, 32)
+	for j := 0; j < 256; j += 8 {
+		res = append(res, uints.U8{Val: api.FromBinary(bits[j : j+8]...)})
+	}
+	return res
+}
+
+// nativeMerkleHasher mirrors MerkleHasher. Digests are 32 bytes, the
+// canonical little-endian encoding for the field hashes.
+type nativeMerkleHasher interface {
+	readRoot(arthur native.Arthur) ([]byte, error)
+	digest(bytes []byte) []byte
+	hashLeaf(leaf []fr.Element) ([]byte, error)
+	compress(children ...[]byte) []byte
+}
+
+func newNativeMerkleHasher(hash Hash, leaf LeafHash) (nativeMerkleHasher, error) {
+	switch hash {
+	case HashSkyscraper, "":
+		return newNativeFieldMerkleHasher(native.SkyscraperCompress, leaf, func(leaf ...fr.Element) fr.Element {
+			state := [2]fr.Element{}
+			for _, element := range leaf {
+				state[0] = element
+				native.SkyscraperPermute(&state)
+			}
+			return state[0]
+		})
+	case HashKeccak:
+		return nativeKeccakMerkleHasher{leaf: leaf}, nil
+	case HashMiMC:
+		return newNativeFieldMerkleHasher(func(l, r fr.Element) fr.Element { return native.MiMCHash(l, r) }, leaf, native.MiMCHash)
+	case HashPoseidon2:
+		return newNativeFieldMerkleHasher(native.Poseidon2Compress, leaf, native.Poseidon2HashLeaf)
+	}
+	return nil, fmt.Errorf("unknown merkle hash %q", hash)
+}
+
+func newNativeArthur(circuit *Circuit) (native.Arthur, error) {
+	switch circuit.TranscriptHash {
+	case HashSkyscraper, "":
+		return native.NewSkyscraperArthur(circuit.IO, bytesOf(circuit.transcript()))
+	case HashKeccak:
+		return native.NewKeccakArthur(circuit.IO, bytesOf(circuit.transcript()))
+	case HashPoseidon2:
+		return native.NewPoseidon2Arthur(circuit.IO, bytesOf(circuit.transcript()))
+	case HashMiMC:
+		return native.NewMiMCArthur(circuit.IO, bytesOf(circuit.transcript()))
+	}
+	return nil, fmt.Errorf("unknown transcript hash %q", circuit.TranscriptHash)
+}
+
+// powBound returns the largest hash the PoWHasher of the given hash accepts.
+// Keccak grinds the first lane of its state, so the mode only applies to the
+// field hashes.
+func powBound(hash Hash, difficulty int, mode utilities.PoWMode) (*big.Int, error) {
+	if hash == HashKeccak {
+		return utilities.KeccakPoWBound(difficulty)
+	}
+	return utilities.PoWBound(difficulty, mode)
+}
+
+// nativePoWHash mirrors the PoWHasher of the circuit, returning the hash as
+// the integer compared against powBound.
+func nativePoWHash(hash Hash, challenge []byte, nonce []byte) (*big.Int, error) {
+	var compress func(l, r fr.Element) fr.Element
+	switch hash {
+	case HashSkyscraper, "":
+		compress = native.SkyscraperCompress
+	case HashMiMC:
+		compress = func(l, r fr.Element) fr.Element { return native.MiMCHash(l, r) }
+	case HashPoseidon2:
+		compress = native.Poseidon2Compress
+	case HashKeccak:
+		var state [25]uint64
+		for i := range 4 {
+			state[i] = binary.LittleEndian.Uint64(challenge[8*i:])
+		}
+		state[4] = binary.BigEndian.Uint64(nonce)
+		native.KeccakF1600(&state)
+		return new(big.Int).SetUint64(state[0]), nil
+	default:
+		return nil, fmt.Errorf("unknown proof of work hash %q", hash)
+	}
+	h := compress(native.FromLittleEndian(challenge), native.FromBigEndian(nonce))
+	return h.BigInt(new(big.Int)), nil
+}
+
+type nativeFieldMerkleHasher struct {
+	hash       func(l, r fr.Element) fr.Element
+	leaf       LeafHash
+	leafDigest func(leaf ...fr.Element) fr.Element
+}
+
+func newNativeFieldMerkleHasher(hash func(l, r fr.Element) fr.Element, leaf LeafHash, sponge func(leaf ...fr.Element) fr.Element) (nativeMerkleHasher, error) {
+	h := nativeFieldMerkleHasher{hash: hash, leaf: leaf}
+	switch leaf {
+	case LeafChain:
+		h.leafDigest = func(leaf ...fr.Element) fr.Element {
+			res := leaf[0]
+			for _, element := range leaf[1:] {
+				res = hash(res, element)
+			}
+			return res
+		}
+	case LeafSingle:
+		h.leafDigest = func(leaf ...fr.Element) fr.Element {
+			return leaf[0]
+		}
+	case LeafSponge:
+		h.leafDigest = sponge
+	case LeafKeccak:
+		h.leafDigest = func(leaf ...fr.Element) fr.Element {
+			digest := native.Keccak256(nativeSerializeLeaf(leaf))
+			return native.FromLittleEndian(digest[:])
+		}
+	default:
+		return nil, fmt.Errorf("unknown leaf hash %q", leaf)
+	}
+	return h, nil
+}
+
+func (h nativeFieldMerkleHasher) readRoot(arthur native.Arthur) ([]byte, error) {
+	root := make([]fr.Element, 1)
+	if err := arthur.FillNextScalars(root); err != nil {
+		return nil, err
+	}
+	return leBytes(root[0]), nil
+}
+
+func (h nativeFieldMerkleHasher) digest(bytes []byte) []byte {
+	return leBytes(native.FromLittleEndian(bytes))
+}
+
+func (h nativeFieldMerkleHasher) hashLeaf(leaf []fr.Element) ([]byte, error) {
+	if err := checkLeafSize(h.leaf, len(leaf)); err != nil {
+		return nil, err
+	}
+	return leBytes(h.leafDigest(leaf...)), nil
+}
+
+func (h nativeFieldMerkleHasher) compress(children ...[]byte) []byte {
+	res := native.FromLittleEndian(children[0])
+	for _, child := range children[1:] {
+		res = h.hash(res, native.FromLittleEndian(child))
+	}
+	return leBytes(res)
+}
+
+func leBytes(e fr.Element) []byte {
+	bytes := native.LittleEndianBytes(e)
+	return bytes[:]
+}
+
+type nativeKeccakMerkleHasher struct {
+	leaf LeafHash
+}
+
+func (nativeKeccakMerkleHasher) readRoot(arthur native.Arthur) ([]byte, error) {
+	root := make([]byte, 32)
+	return root, arthur.FillNextBytes(root)
+}
+
+func (nativeKeccakMerkleHasher) digest(bytes []byte) []byte {
+	return bytes
+}
+
+func (h nativeKeccakMerkleHasher) hashLeaf(leaf []fr.Element) ([]byte, error) {
+	if err := checkLeafSize(h.leaf, len(leaf)); err != nil {
+		return nil, err
+	}
+	if h.leaf == LeafSingle {
+		return leBytes(leaf[0]), nil
+	}
+	digest := native.Keccak256(nativeSerializeLeaf(leaf))
+	return digest[:], nil
+}
+
+func (nativeKeccakMerkleHasher) compress(children ...[]byte) []byte {
+	var node []byte
+	for _, child := range children {
+		node = append(node, child...)
+	}
+	digest := native.Keccak256(node)
+	return digest[:]
+}
+
+func nativeSerializeLeaf(leaf []fr.Element) []byte {
+	res := binary.LittleEndian.AppendUint64(nil, uint64(len(leaf)))
+	for _, element := range leaf {
+		bytes := native.LittleEndianBytes(element)
+		res = append(res, bytes[:]...)
+	}
+	return res
+}
@@ -0,0 +1,111 @@
+package whir
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+
+	gnark_nimue "github.com/reilabs/gnark-nimue"
+	go_ark_serialize "github.com/reilabs/go-ark-serialize"
+)
+
+func loadInputs(proofPath string, paramsPath string, r1csPath string) (ProofObject, Config, R1CS, Interner, error) {
+	proofFile, err := os.Open(proofPath)
+	if err != nil {
+		return ProofObject{}, Config{}, R1CS{}, Interner{}, err
+	}
+	defer proofFile.Close()
+
+	var proof ProofObject
+	_, err = go_ark_serialize.CanonicalDeserializeWithMode(proofFile, &proof, false, false)
+	if err != nil {
+		return ProofObject{}, Config{}, R1CS{}, Interner{}, fmt.Errorf("deserializing proof %s: %w", proofPath, err)
+	}
+
+	config, err := loadParams(paramsPath)
+	if err != nil {
+		return ProofObject{}, Config{}, R1CS{}, Interner{}, err
+	}
+
+	r1csFile, err := os.ReadFile(r1csPath)
+	if err != nil {
+		return ProofObject{}, Config{}, R1CS{}, Interner{}, err
+	}
+
+	var r1cs R1CS
+	if err := json.Unmarshal(r1csFile, &r1cs); err != nil {
+		return ProofObject{}, Config{}, R1CS{}, Interner{}, fmt.Errorf("unmarshalling r1cs %s: %w", r1csPath, err)
+	}
+
+	internerBytes, err := hex.DecodeString(r1cs.Interner.Values)
+	if err != nil {
+		return ProofObject{}, Config{}, R1CS{}, Interner{}, fmt.Errorf("decoding interner: %w", err)
+	}
+
+	var interner Interner
+	_, err = go_ark_serialize.CanonicalDeserializeWithMode(bytes.NewReader(internerBytes), &interner, false, false)
+	if err != nil {
+		return ProofObject{}, Config{}, R1CS{}, Interner{}, fmt.Errorf("deserializing interner: %w", err)
+	}
+
+	return proof, config, r1cs, interner, nil
+}
+
+func loadParams(path string) (Config, error) {
+	configFile, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	var config Config
+	if err := json.Unmarshal(configFile, &config); err != nil {
+		return Config{}, fmt.Errorf("unmarshalling params %s: %w", path, err)
+	}
+
+	io := gnark_nimue.IOPattern{}
+	if err := io.Parse([]byte(config.IOPattern)); err != nil {
+		return Config{}, fmt.Errorf("parsing io pattern: %w", err)
+	}
+	return config, nil
+}
+
+// LoadShapes reads the params files of the instance sizes a universal
+// circuit admits, see UniversalBounds.Shapes.
+func LoadShapes(paths []string) ([]Config, error) {
+	shapes := make([]Config, len(paths))
+	for i, path := range paths {
+		config, err := loadParams(path)
+		if err != nil {
+			return nil, err
+		}
+		shapes[i] = ShapeParams(config)
+	}
+	return shapes, nil
+}
+
+// LoadPublicInputs reads the values of the R1CS public inputs from a JSON
+// array of decimal strings, in the order of the witness.
+func LoadPublicInputs(path string) ([]*big.Int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var strs []string
+	if err := json.Unmarshal(data, &strs); err != nil {
+		return nil, fmt.Errorf("unmarshalling public inputs %s: %w", path, err)
+	}
+	values := make([]*big.Int, len(strs))
+	for i, str := range strs {
+		x, ok := new(big.Int).SetString(str, 10)
+		if !ok || x.Sign() < 0 || x.Cmp(fr.Modulus()) >= 0 {
+			return nil, fmt.Errorf("public input %d of %s is not a field element: %q", i, path, str)
+		}
+		values[i] = x
+	}
+	return values, nil
+}
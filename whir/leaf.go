@@ -0,0 +1,52 @@
+package whir
+
+import "fmt"
+
+// LeafHash names how the field elements of a Merkle leaf are hashed to a
+// node of the tree.
+type LeafHash string
+
+const (
+	// LeafChain compresses the elements from left to right, starting from
+	// the first one. It is the default for Skyscraper.
+	LeafChain LeafHash = "chain"
+	// LeafSingle takes the only element of the leaf as its node.
+	LeafSingle LeafHash = "single"
+	// LeafSponge absorbs the elements into a zeroed sponge of the Merkle hash
+	// and squeezes one element. It is the default for MiMC and Poseidon2.
+	LeafSponge LeafHash = "sponge"
+	// LeafKeccak hashes the arkworks serialization of the elements with
+	// Keccak-256, read as a little-endian field element for the algebraic
+	// hashes. It is the default for Keccak.
+	LeafKeccak LeafHash = "keccak"
+)
+
+func ParseLeafHash(s string) (LeafHash, error) {
+	switch leaf := LeafHash(s); leaf {
+	case LeafChain, LeafSingle, LeafSponge, LeafKeccak:
+		return leaf, nil
+	}
+	return "", fmt.Errorf("unknown leaf hash %q, expected chain, single, sponge or keccak", s)
+}
+
+func defaultLeafHash(merkle Hash) LeafHash {
+	switch merkle {
+	case HashKeccak:
+		return LeafKeccak
+	case HashMiMC, HashPoseidon2:
+		return LeafSponge
+	}
+	return LeafChain
+}
+
+// checkLeafSize returns an error if a leaf of size elements cannot be hashed
+// with the given scheme.
+func checkLeafSize(leaf LeafHash, size int) error {
+	if size == 0 {
+		return fmt.Errorf("cannot hash an empty leaf")
+	}
+	if leaf == LeafSingle && size != 1 {
+		return fmt.Errorf("single element leaf hash cannot hash a leaf of %d elements", size)
+	}
+	return nil
+}
@@ -0,0 +1,718 @@
+package whir
+
+import (
+	"fmt"
+	"math/big"
+	"math/bits"
+	"reilabs/whir-verifier-circuit/typeConverters"
+	"reilabs/whir-verifier-circuit/utilities"
+
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/math/uints"
+)
+
+func (circuit *Circuit) Define(api frontend.API) error {
+	if len(circuit.Shapes) > 0 {
+		return circuit.defineShapes(api)
+	}
+	return circuit.verify(api)
+}
+
+// verify runs the WHIR verifier of the circuit shape.
+func (circuit *Circuit) verify(api frontend.API) error {
+	sc, arthur, uapi, h, err := initializeComponents(api, circuit)
+	if err != nil {
+		return err
+	}
+	if err := checkTranscriptDigest(api, sc, circuit); err != nil {
+		return err
+	}
+	if err := checkMatrixDigest(api, sc, circuit); err != nil {
+		return err
+	}
+
+	t_rand, sp_rand, savedValForSumcheck, err := SumcheckForR1CSIOP(api, arthur, circuit)
+	if err != nil {
+		return err
+	}
+	api.Println(t_rand)
+	api.Println(sp_rand)
+	api.Println(savedValForSumcheck)
+
+	rootHashes, batchingRandomness, initialOODQueries, initialOODAnswers, err := parseBatchedCommitment(api, arthur, circuit, h.merkle)
+	if err != nil {
+		return err
+	}
+
+	initialOODs := oodAnswers(api, initialOODAnswers, batchingRandomness)
+
+	if len(circuit.PublicInputs) > 0 {
+		bindPublicInputs(api, circuit, initialOODQueries[0])
+	}
+
+	batchSizeLen := circuit.BatchSize
+
+	initialSumcheckData, lastEval, initialSumcheckFoldingRandomness, err := initialSumcheck(api, circuit, arthur, initialOODQueries, initialOODs)
+
+	if err != nil {
+		return err
+	}
+	copyOfFirstLeaves := make([][][]frontend.Variable, len(circuit.FirstRoundPaths.Leaves))
+	for i := range len(circuit.FirstRoundPaths.Leaves) {
+		copyOfFirstLeaves[i] = make([][]frontend.Variable, len(circuit.FirstRoundPaths.Leaves[i]))
+		for j := range len(circuit.FirstRoundPaths.Leaves[i]) {
+			copyOfFirstLeaves[i][j] = make([]frontend.Variable, len(circuit.FirstRoundPaths.Leaves[i][j]))
+			for k := range len(circuit.FirstRoundPaths.Leaves[i][j]) {
+				copyOfFirstLeaves[i][j][k] = circuit.FirstRoundPaths.Leaves[i][j][k]
+			}
+		}
+	}
+
+	computedFolded := combineFirstRoundLeaves(api, copyOfFirstLeaves, batchingRandomness)
+	roundAnswers := make([][][]frontend.Variable, len(circuit.MerklePaths.Leaves)+1)
+	roundAnswers[0] = computedFolded
+	for i := range len(circuit.MerklePaths.Leaves) {
+		roundAnswers[i+1] = circuit.MerklePaths.Leaves[i]
+	}
+
+	computedFold := foldLeaves(api, uapi, circuit, computedFolded, circuit.FirstRoundPaths.LeafIndexes[0], initialSumcheckFoldingRandomness, 0)
+
+	mainRoundData := generateEmptyMainRoundData(circuit)
+	expDomainGenerator := utilities.Exponent(api, uapi, circuit.StartingDomainBackingDomainGenerator, uints.NewU64(uint64(1<<circuit.FoldingFactorArray[0])))
+	domainSize := circuit.DomainSize
+
+	totalFoldingRandomness := initialSumcheckFoldingRandomness
+
+	rootHashList := make([]Digest, len(circuit.RoundParametersOODSamples))
+
+	for r := range circuit.RoundParametersOODSamples {
+
+		rootHashList[r], err = h.merkle.ReadRoot(arthur)
+		if err != nil {
+			return err
+		}
+
+		a, roundOODAnswers, err := FillInOODPointsAndAnswers(circuit.RoundParametersOODSamples[r], arthur)
+		if err != nil {
+			return err
+		}
+
+		mainRoundData.OODPoints[r] = a
+
+		stirChallengeIndexes, err := GetStirChallenges(api, *circuit, arthur, circuit.RoundParametersNumOfQueries[r], domainSize, r)
+		if err != nil {
+			return err
+		}
+		indexBits := bits.Len(uint(domainSize>>circuit.FoldingFactorArray[r])) - 1
+
+		if r == 0 {
+			err = ValidateFirstRound(api, circuit, arthur, uapi, h.merkle, batchSizeLen, rootHashes, batchingRandomness, stirChallengeIndexes, roundAnswers[0])
+			if err != nil {
+				return err
+			}
+
+			mainRoundData.StirChallengesPoints[r] = make([]frontend.Variable, len(circuit.FirstRoundPaths.LeafIndexes[r]))
+			for index := range circuit.FirstRoundPaths.LeafIndexes[r] {
+				mainRoundData.StirChallengesPoints[r][index] = utilities.FixedBaseExponent(api, expDomainGenerator, uapi.ToValue(circuit.FirstRoundPaths.LeafIndexes[r][index]), indexBits)
+			}
+		} else {
+			err := circuit.MerklePaths.verify(api, uapi, h.merkle, circuit.MerkleArity, r-1, roundAnswers[r], rootHashList[r-1])
+			if err != nil {
+				return err
+			}
+			err = circuit.MerklePaths.isSubset(api, uapi, circuit.MerkleArity, r-1, stirChallengeIndexes, circuit.Universal)
+			if err != nil {
+				return err
+			}
+			mainRoundData.StirChallengesPoints[r] = make([]frontend.Variable, len(circuit.MerklePaths.LeafIndexes[r-1]))
+			for index := range circuit.MerklePaths.LeafIndexes[r-1] {
+				mainRoundData.StirChallengesPoints[r][index] = utilities.FixedBaseExponent(api, expDomainGenerator, uapi.ToValue(circuit.MerklePaths.LeafIndexes[r-1][index]), indexBits)
+			}
+		}
+
+		if err = RunPoW(h.pow, arthur, circuit.PowBits[r], circuit.PoWMode); err != nil {
+			return err
+		}
+
+		mainRoundData.CombinationRandomness[r], err = GenerateCombinationRandomness(api, arthur, len(roundOODAnswers)+len(computedFold))
+		if err != nil {
+			return err
+		}
+		if circuit.Universal {
+			leafIndexes := circuit.FirstRoundPaths.LeafIndexes[0]
+			if r > 0 {
+				leafIndexes = circuit.MerklePaths.LeafIndexes[r-1]
+			}
+			maskPaddedOpenings(api, uapi, mainRoundData.CombinationRandomness[r][len(roundOODAnswers):], leafIndexes)
+		}
+
+		lastEval = api.Add(lastEval, calculateShiftValue(roundOODAnswers, mainRoundData.CombinationRandomness[r], computedFold, api))
+
+		roundFoldingRandomness := []frontend.Variable{}
+		roundFoldingRandomness, lastEval, err = runSumcheckRounds(api, lastEval, arthur, circuit.FoldingFactorArray[r], 2)
+		if err != nil {
+			return err
+		}
+
+		computedFold = foldLeaves(api, uapi, circuit, circuit.MerklePaths.Leaves[r], circuit.MerklePaths.LeafIndexes[r], roundFoldingRandomness, r+1)
+		totalFoldingRandomness = append(totalFoldingRandomness, roundFoldingRandomness...)
+
+		domainSize /= 2
+		expDomainGenerator = api.Mul(expDomainGenerator, expDomainGenerator)
+	}
+
+	finalCoefficients, finalRandomnessPoints, err := generateFinalCoefficientsAndRandomnessPoints(api, arthur, circuit, uapi, h.pow, domainSize, expDomainGenerator)
+	if err != nil {
+		return err
+	}
+
+	finalEvaluations := utilities.UnivarPoly(api, finalCoefficients, finalRandomnessPoints)
+
+	for foldIndex := range computedFold {
+		api.AssertIsEqual(computedFold[foldIndex], finalEvaluations[foldIndex])
+	}
+
+	finalSumcheckRandomness, lastEval, err := runSumcheckRounds(api, lastEval, arthur, circuit.FinalSumcheckRounds, 2)
+	if err != nil {
+		return err
+	}
+
+	totalFoldingRandomness = append(totalFoldingRandomness, finalSumcheckRandomness...)
+
+	if circuit.FinalFoldingPowBits > 0 {
+		_, _, err := utilities.PoW(h.pow, arthur, circuit.FinalFoldingPowBits, circuit.PoWMode)
+		if err != nil {
+			return err
+		}
+	}
+
+	evaluationOfWPoly := ComputeWPoly(
+		api,
+		circuit,
+		initialOODQueries,
+		initialSumcheckData,
+		mainRoundData,
+		sp_rand,
+		totalFoldingRandomness,
+	)
+
+	api.AssertIsEqual(
+		lastEval,
+		api.Mul(evaluationOfWPoly, utilities.MultivarPoly(finalCoefficients, finalSumcheckRandomness, api)),
+	)
+
+	// The R1CS sumcheck reduces satisfiability to (a·b - c)·eq(sp_rand, t_rand),
+	// where a, b and c are the matrix evaluations proved by WHIR above.
+	x := api.Mul(api.Sub(api.Mul(circuit.LinearStatementEvaluations[0], circuit.LinearStatementEvaluations[1]), circuit.LinearStatementEvaluations[2]), calculateEQ(api, sp_rand, t_rand))
+	api.AssertIsEqual(savedValForSumcheck, x)
+	return nil
+}
+
+func oodAnswers(
+	api frontend.API,
+	answers [][]frontend.Variable,
+	randomness frontend.Variable,
+) (result []frontend.Variable) {
+
+	if len(answers) == 0 {
+		return nil
+	}
+
+	multiplier := frontend.Variable(1)
+
+	first := answers[0]
+	result = make([]frontend.Variable, len(first))
+	for j := range first {
+		result[j] = api.Mul(first[j], multiplier)
+	}
+
+	for i := 1; i < len(answers); i++ {
+		multiplier = api.Mul(multiplier, randomness)
+
+		round := answers[i]
+		for j := range round {
+			term := api.Mul(round[j], multiplier)
+			result[j] = api.Add(result[j], term)
+		}
+	}
+
+	return result
+}
+
+type MerkleObject struct {
+	AuthPaths                  [][][][]uints.U8
+	Leaves                     [][][]frontend.Variable
+	LeafSiblingHashes          [][][]uints.U8
+	LeafIndexes                [][]uints.U64
+	ContainerAuthPaths         [][][][]uints.U8
+	ContainerLeaves            [][][]frontend.Variable
+	ContainerLeafSiblingHashes [][][]uints.U8
+	ContainerLeafIndexes       [][]uints.U64
+}
+
+// ParsePathsObject expands the prefix compressed paths of the proof for a
+// tree of the given arity. Every level of a path holds arity-1 consecutive
+// siblings, in the order of the children, and the prefix lengths count
+// levels. The siblings of a level are concatenated in the expanded paths.
+func ParsePathsObject(proofElements []ProofElement, arity int) (MerkleObject, error) {
+	var totalAuthPath = make([][][][]uints.U8, len(proofElements))
+	var totalLeaves = make([][][]frontend.Variable, len(proofElements))
+	var totalLeafSiblingHashes = make([][][]uints.U8, len(proofElements))
+	var totalLeafIndexes = make([][]uints.U64, len(proofElements))
+
+	var containerTotalAuthPath = make([][][][]uints.U8, len(proofElements))
+	var containerTotalLeaves = make([][][]frontend.Variable, len(proofElements))
+	var containerTotalLeafSiblingHashes = make([][][]uints.U8, len(proofElements))
+	var containerTotalLeafIndexes = make([][]uints.U64, len(proofElements))
+
+	siblings := arity - 1
+	for i := range proofElements {
+		var numOfLeavesProved = len(proofElements[i].A.LeafIndexes)
+		if numOfLeavesProved == 0 {
+			return MerkleObject{}, fmt.Errorf("paths %d open no leaves", i)
+		}
+		if len(proofElements[i].A.AuthPathsSuffixes) != numOfLeavesProved || len(proofElements[i].A.AuthPathsPrefixLengths) != numOfLeavesProved {
+			return MerkleObject{}, fmt.Errorf("paths %d have %d suffixes and %d prefix lengths for %d leaves", i, len(proofElements[i].A.AuthPathsSuffixes), len(proofElements[i].A.AuthPathsPrefixLengths), numOfLeavesProved)
+		}
+		if len(proofElements[i].B) != numOfLeavesProved {
+			return MerkleObject{}, fmt.Errorf("paths %d have %d leaves for %d leaf indexes", i, len(proofElements[i].B), numOfLeavesProved)
+		}
+		if len(proofElements[i].A.LeafSiblingHashes) != numOfLeavesProved*siblings {
+			return MerkleObject{}, fmt.Errorf("paths %d have %d leaf siblings for %d leaves of a tree of arity %d", i, len(proofElements[i].A.LeafSiblingHashes), numOfLeavesProved, arity)
+		}
+		suffixes := make([][][]KeccakDigest, numOfLeavesProved)
+		for j := range suffixes {
+			if len(proofElements[i].A.AuthPathsSuffixes[j])%siblings != 0 {
+				return MerkleObject{}, fmt.Errorf("path %d of paths %d has %d siblings, not a multiple of %d", j, i, len(proofElements[i].A.AuthPathsSuffixes[j]), siblings)
+			}
+			suffixes[j] = levels(proofElements[i].A.AuthPathsSuffixes[j], siblings)
+		}
+		var treeHeight = len(suffixes[0])
+
+		totalAuthPath[i] = make([][][]uints.U8, numOfLeavesProved)
+		containerTotalAuthPath[i] = make([][][]uints.U8, numOfLeavesProved)
+		totalLeaves[i] = make([][]frontend.Variable, numOfLeavesProved)
+		containerTotalLeaves[i] = make([][]frontend.Variable, numOfLeavesProved)
+		totalLeafSiblingHashes[i] = make([][]uints.U8, numOfLeavesProved)
+		containerTotalLeafSiblingHashes[i] = make([][]uints.U8, numOfLeavesProved)
+
+		for j := range numOfLeavesProved {
+			totalAuthPath[i][j] = make([][]uints.U8, treeHeight)
+			containerTotalAuthPath[i][j] = make([][]uints.U8, treeHeight)
+
+			for z := range treeHeight {
+				containerTotalAuthPath[i][j][z] = make([]uints.U8, 32*siblings)
+			}
+			totalLeaves[i][j] = make([]frontend.Variable, len(proofElements[i].B[j]))
+			containerTotalLeaves[i][j] = make([]frontend.Variable, len(proofElements[i].B[j]))
+			containerTotalLeafSiblingHashes[i][j] = make([]uints.U8, 32*siblings)
+		}
+
+		containerTotalLeafIndexes[i] = make([]uints.U64, numOfLeavesProved)
+
+		var prevPath = suffixes[0]
+		for j := range numOfLeavesProved {
+			if j > 0 {
+				prefixLen := proofElements[i].A.AuthPathsPrefixLengths[j]
+				if prefixLen > uint64(len(prevPath)) {
+					return MerkleObject{}, fmt.Errorf("path %d of paths %d shares %d levels with a path of %d levels", j, i, prefixLen, len(prevPath))
+				}
+				prevPath = utilities.PrefixDecodePath(prevPath, prefixLen, suffixes[j])
+			}
+			if len(prevPath) != treeHeight {
+				return MerkleObject{}, fmt.Errorf("path %d of paths %d has %d levels, expected %d", j, i, len(prevPath), treeHeight)
+			}
+			path := utilities.Reverse(prevPath)
+			for z := range treeHeight {
+				totalAuthPath[i][j][z] = concatDigests(path[z])
+			}
+		}
+		totalLeafIndexes[i] = make([]uints.U64, numOfLeavesProved)
+
+		for z := range numOfLeavesProved {
+			totalLeafSiblingHashes[i][z] = concatDigests(proofElements[i].A.LeafSiblingHashes[z*siblings : (z+1)*siblings])
+			totalLeafIndexes[i][z] = uints.NewU64(proofElements[i].A.LeafIndexes[z])
+			for j := range proofElements[i].B[z] {
+				input := proofElements[i].B[z][j]
+				totalLeaves[i][z][j] = typeConverters.LimbsToBigIntMod(input.Limbs)
+			}
+		}
+	}
+
+	return MerkleObject{
+		AuthPaths:                  totalAuthPath,
+		Leaves:                     totalLeaves,
+		LeafSiblingHashes:          totalLeafSiblingHashes,
+		LeafIndexes:                totalLeafIndexes,
+		ContainerAuthPaths:         containerTotalAuthPath,
+		ContainerLeaves:            containerTotalLeaves,
+		ContainerLeafSiblingHashes: containerTotalLeafSiblingHashes,
+		ContainerLeafIndexes:       containerTotalLeafIndexes,
+	}, nil
+}
+
+// levels groups the digests of a path into the siblings of every level.
+func levels(digests []KeccakDigest, siblings int) [][]KeccakDigest {
+	res := make([][]KeccakDigest, len(digests)/siblings)
+	for i := range res {
+		res[i] = digests[i*siblings : (i+1)*siblings]
+	}
+	return res
+}
+
+func concatDigests(digests []KeccakDigest) []uints.U8 {
+	res := make([]uints.U8, 0, 32*len(digests))
+	for _, digest := range digests {
+		res = append(res, uints.NewU8Array(digest.KeccakDigest[:])...)
+	}
+	return res
+}
+
+// pathsLayout returns the leaf indexes opened by every paths of the proof.
+func pathsLayout(proofElements []ProofElement) [][]uint64 {
+	layout := make([][]uint64, len(proofElements))
+	for i := range proofElements {
+		layout[i] = append([]uint64{}, proofElements[i].A.LeafIndexes...)
+	}
+	return layout
+}
+
+// merkleArity returns the arity of the Merkle trees of the params, 2 when
+// left out. Leaf indexes are split into base arity digits bit by bit, so it
+// has to be a power of two.
+func merkleArity(cfg Config) (int, error) {
+	if cfg.MerkleArity == 0 {
+		return 2, nil
+	}
+	if cfg.MerkleArity < 2 || cfg.MerkleArity&(cfg.MerkleArity-1) != 0 {
+		return 0, fmt.Errorf("merkle arity %d is not a power of two", cfg.MerkleArity)
+	}
+	return cfg.MerkleArity, nil
+}
+
+// matrixCells expands a CSR matrix into its entries, in row order.
+func matrixCells(matrix SparseMatrix, interner Interner) []MatrixCell {
+	cells := make([]MatrixCell, len(matrix.Values))
+	for i := range len(matrix.RowIndices) {
+		end := len(matrix.Values) - 1
+		if i < len(matrix.RowIndices)-1 {
+			end = int(matrix.RowIndices[i+1] - 1)
+		}
+		for j := int(matrix.RowIndices[i]); j <= end; j++ {
+			cells[j] = MatrixCell{
+				row:    i,
+				column: int(matrix.ColIndices[j]),
+				value:  typeConverters.LimbsToBigIntMod(interner.Values[matrix.Values[j]].Limbs),
+			}
+		}
+	}
+	return cells
+}
+
+// foldingFactors returns the folding factor of every round, including the
+// final one, and the number of final sumcheck rounds.
+func foldingFactors(cfg Config) ([]int, int) {
+	if len(cfg.FoldingFactor) > 1 {
+		foldingFactor := append(append([]int{}, cfg.FoldingFactor...), cfg.FoldingFactor[len(cfg.FoldingFactor)-1])
+		return foldingFactor, cfg.NVars % foldingFactor[len(foldingFactor)-1]
+	}
+	return []int{4}, cfg.NVars % 4
+}
+
+// startingDomainSize returns the size of the evaluation domain of the
+// committed polynomial.
+func startingDomainSize(cfg Config) int {
+	return (2 << cfg.NVars) * (1 << cfg.Rate) / 2
+}
+
+// buildCircuits returns the circuit used for compilation, whose Merkle data is
+// zero-valued, together with the full witness assignment for the given proof.
+func buildCircuits(proof ProofObject, cfg Config, internedR1CS R1CS, interner Interner, s settings) (Circuit, Circuit, error) {
+	if s.Universal != nil {
+		return buildUniversalCircuits(proof, cfg, internedR1CS, interner, s)
+	}
+	return buildShapeCircuits(proof, cfg, internedR1CS, interner, s)
+}
+
+// buildShapeCircuits is buildCircuits for a single circuit shape, the one of
+// the params.
+func buildShapeCircuits(proof_arg ProofObject, cfg Config, internedR1CS R1CS, interner Interner, s settings) (Circuit, Circuit, error) {
+	arity, err := merkleArity(cfg)
+	if err != nil {
+		return Circuit{}, Circuit{}, err
+	}
+	merkleObject, err := ParsePathsObject(proof_arg.MerklePaths, arity)
+	if err != nil {
+		return Circuit{}, Circuit{}, err
+	}
+	firstRoundMerkleObject, err := ParsePathsObject(proof_arg.FirstRoundPaths, arity)
+	if err != nil {
+		return Circuit{}, Circuit{}, err
+	}
+
+	startingDomainGen, ok := new(big.Int).SetString(cfg.DomainGenerator, 10)
+	if !ok {
+		return Circuit{}, Circuit{}, fmt.Errorf("invalid domain generator %q", cfg.DomainGenerator)
+	}
+	if len(cfg.StatementEvaluations) != len(proof_arg.StatementValuesAtRandomPoint) {
+		return Circuit{}, Circuit{}, fmt.Errorf("params have %d statement evaluations but the proof has %d statement values", len(cfg.StatementEvaluations), len(proof_arg.StatementValuesAtRandomPoint))
+	}
+	// The statements are the evaluations of the A, B and C matrices, followed
+	// by the public input statement when the R1CS has public inputs.
+	numStatements := 3
+	if internedR1CS.PublicInputs > 0 {
+		numStatements = 4
+	}
+	if len(cfg.StatementEvaluations) != numStatements {
+		return Circuit{}, Circuit{}, fmt.Errorf("expected %d statement evaluations, params have %d", numStatements, len(cfg.StatementEvaluations))
+	}
+	if uint64(len(s.PublicInputs)) != internedR1CS.PublicInputs {
+		return Circuit{}, Circuit{}, fmt.Errorf("r1cs has %d public inputs but %d public input values are given", internedR1CS.PublicInputs, len(s.PublicInputs))
+	}
+	powHash, err := resolveHash("", cfg.PoWHash)
+	if err != nil {
+		return Circuit{}, Circuit{}, err
+	}
+	for _, bits := range append([]int{cfg.FinalPowBits, cfg.FinalFoldingPowBits}, cfg.PowBits...) {
+		if _, err := powBound(powHash, bits, s.PoWMode); err != nil {
+			return Circuit{}, Circuit{}, err
+		}
+	}
+	mvParamsNumberOfVariables := cfg.NVars
+	foldingFactor, finalSumcheckRounds := foldingFactors(cfg)
+	domainSize := startingDomainSize(cfg)
+	oodSamples := cfg.OODSamples
+	numOfQueries := cfg.NumQueries
+	powBits := cfg.PowBits
+	finalQueries := cfg.FinalQueries
+	nRounds := cfg.NRounds
+	statementPoints := make([][]frontend.Variable, 1)
+	statementPoints[0] = make([]frontend.Variable, mvParamsNumberOfVariables)
+	contStatementPoints := make([][]frontend.Variable, 1)
+	contStatementPoints[0] = make([]frontend.Variable, mvParamsNumberOfVariables)
+	for i := range mvParamsNumberOfVariables {
+		statementPoints[0][i] = frontend.Variable(0)
+		contStatementPoints[0][i] = frontend.Variable(0)
+	}
+
+	transcriptT := make([]uints.U8, cfg.TranscriptLen)
+	contTranscript := make([]uints.U8, cfg.TranscriptLen)
+
+	for i := range cfg.Transcript {
+		transcriptT[i] = uints.NewU8(cfg.Transcript[i])
+		contTranscript[i] = uints.NewU8(cfg.Transcript[i])
+	}
+
+	// Outside of TranscriptPublic the transcript moves to the private witness
+	// and only its digest is public.
+	var privateTranscript, contPrivateTranscript []uints.U8
+	var transcriptDigest, contTranscriptDigest []frontend.Variable
+	if s.TranscriptMode != TranscriptPublic {
+		if len(cfg.Transcript) != cfg.TranscriptLen {
+			return Circuit{}, Circuit{}, fmt.Errorf("transcript has %d bytes, expected %d", len(cfg.Transcript), cfg.TranscriptLen)
+		}
+		privateTranscript, contPrivateTranscript = transcriptT, contTranscript
+		transcriptT, contTranscript = nil, nil
+		for _, x := range TranscriptDigest(s.TranscriptMode, cfg.Transcript) {
+			transcriptDigest = append(transcriptDigest, x)
+			contTranscriptDigest = append(contTranscriptDigest, x)
+		}
+	}
+
+	linearStatementValuesAtPoints := make([]frontend.Variable, len(proof_arg.StatementValuesAtRandomPoint))
+	contLinearStatementValuesAtPoints := make([]frontend.Variable, len(proof_arg.StatementValuesAtRandomPoint))
+
+	linearStatementEvaluations := make([]frontend.Variable, len(cfg.StatementEvaluations))
+	contLinearStatementEvaluations := make([]frontend.Variable, len(cfg.StatementEvaluations))
+	for i := range len(proof_arg.StatementValuesAtRandomPoint) {
+		linearStatementValuesAtPoints[i] = typeConverters.LimbsToBigIntMod(proof_arg.StatementValuesAtRandomPoint[i].Limbs)
+		contLinearStatementValuesAtPoints[i] = typeConverters.LimbsToBigIntMod(proof_arg.StatementValuesAtRandomPoint[i].Limbs)
+		x, ok := new(big.Int).SetString(cfg.StatementEvaluations[i], 10)
+		if !ok {
+			return Circuit{}, Circuit{}, fmt.Errorf("invalid statement evaluation %q", cfg.StatementEvaluations[i])
+		}
+		linearStatementEvaluations[i] = frontend.Variable(x)
+		contLinearStatementEvaluations[i] = frontend.Variable(x)
+	}
+
+	publicInputs := make([]frontend.Variable, len(s.PublicInputs))
+	contPublicInputs := make([]frontend.Variable, len(s.PublicInputs))
+	for i, x := range s.PublicInputs {
+		publicInputs[i] = x
+	}
+
+	matrixA := matrixCells(internedR1CS.A, interner)
+	matrixB := matrixCells(internedR1CS.B, interner)
+	matrixC := matrixCells(internedR1CS.C, interner)
+
+	// The universal circuit takes the matrices as inputs instead of
+	// constants, and opens as many leaves as there are queries.
+	// With committed matrices the entries move to the private witness and
+	// only their digest is public.
+	var universalA, universalB, universalC, contUniversalA, contUniversalB, contUniversalC []MatrixEntry
+	var privateA, privateB, privateC, contPrivateA, contPrivateB, contPrivateC []MatrixEntry
+	var matrixDigestT, contMatrixDigest []frontend.Variable
+	if s.CommitMatrices && s.Universal == nil {
+		return Circuit{}, Circuit{}, fmt.Errorf("committed matrices need the universal circuit")
+	}
+	if s.Universal != nil {
+		contUniversalA, universalA = matrixEntries(matrixA, s.Universal.NonZeros)
+		contUniversalB, universalB = matrixEntries(matrixB, s.Universal.NonZeros)
+		contUniversalC, universalC = matrixEntries(matrixC, s.Universal.NonZeros)
+		if s.CommitMatrices {
+			digest := matrixDigest(*s.Universal, matrixA, matrixB, matrixC)
+			matrixDigestT, contMatrixDigest = []frontend.Variable{digest}, []frontend.Variable{digest}
+			privateA, privateB, privateC = universalA, universalB, universalC
+			contPrivateA, contPrivateB, contPrivateC = contUniversalA, contUniversalB, contUniversalC
+			universalA, universalB, universalC = nil, nil, nil
+			contUniversalA, contUniversalB, contUniversalC = nil, nil, nil
+		}
+		matrixA, matrixB, matrixC = nil, nil, nil
+
+		if err := padOpenings(&firstRoundMerkleObject, cfg, true); err != nil {
+			return Circuit{}, Circuit{}, err
+		}
+		if err := padOpenings(&merkleObject, cfg, false); err != nil {
+			return Circuit{}, Circuit{}, err
+		}
+	}
+
+	// Shared nodes compile the leaf indexes of the proof into the circuit.
+	var firstRoundLayout, merkleLayout [][]uint64
+	if s.SharedNodes {
+		if s.Universal != nil {
+			return Circuit{}, Circuit{}, fmt.Errorf("shared nodes need the leaf indexes of the proof, which the universal circuit leaves out")
+		}
+		firstRoundLayout, merkleLayout = pathsLayout(proof_arg.FirstRoundPaths), pathsLayout(proof_arg.MerklePaths)
+	}
+
+	var merklePaths = MerklePaths{
+		Leaves:            merkleObject.ContainerLeaves,
+		LeafIndexes:       merkleObject.ContainerLeafIndexes,
+		LeafSiblingHashes: merkleObject.ContainerLeafSiblingHashes,
+		AuthPaths:         merkleObject.ContainerAuthPaths,
+		Layout:            merkleLayout,
+	}
+	var firstRoundPathsForCircuit = MerklePaths{
+		Leaves:            firstRoundMerkleObject.ContainerLeaves,
+		LeafIndexes:       firstRoundMerkleObject.ContainerLeafIndexes,
+		LeafSiblingHashes: firstRoundMerkleObject.ContainerLeafSiblingHashes,
+		AuthPaths:         firstRoundMerkleObject.ContainerAuthPaths,
+		Layout:            firstRoundLayout,
+	}
+
+	var circuit = Circuit{
+		IO:                                   []byte(cfg.IOPattern),
+		Transcript:                           contTranscript,
+		TranscriptMode:                       s.TranscriptMode,
+		PrivateTranscript:                    contPrivateTranscript,
+		TranscriptDigest:                     contTranscriptDigest,
+		PublicInputs:                         contPublicInputs,
+		RoundParametersOODSamples:            oodSamples,
+		RoundParametersNumOfQueries:          numOfQueries,
+		StartingDomainBackingDomainGenerator: startingDomainGen,
+		ParamNRounds:                         nRounds,
+		FoldOptimisation:                     !s.NaiveFolds,
+		PoWMode:                              s.PoWMode,
+		InitialStatement:                     true,
+		DomainSize:                           domainSize,
+		FoldingFactorArray:                   foldingFactor,
+		MVParamsNumberOfVariables:            mvParamsNumberOfVariables,
+		FinalSumcheckRounds:                  finalSumcheckRounds,
+		PowBits:                              powBits,
+		FinalPowBits:                         cfg.FinalPowBits,
+		FinalFoldingPowBits:                  cfg.FinalFoldingPowBits,
+		FinalQueries:                         finalQueries,
+		StatementPoints:                      contStatementPoints,
+		StatementEvaluations:                 0,
+		BatchSize:                            len(proof_arg.FirstRoundPaths),
+		LinearStatementEvaluations:           contLinearStatementEvaluations,
+		LinearStatementValuesAtPoints:        contLinearStatementValuesAtPoints,
+		MerklePaths:                          merklePaths,
+		FirstRoundPaths:                      firstRoundPathsForCircuit,
+		MerkleArity:                          arity,
+		NVars:                                cfg.NVars,
+		LogNumConstraints:                    cfg.LogNumConstraints,
+		MatrixA:                              matrixA,
+		MatrixB:                              matrixB,
+		MatrixC:                              matrixC,
+		Universal:                            s.Universal != nil,
+		UniversalMatrixA:                     contUniversalA,
+		UniversalMatrixB:                     contUniversalB,
+		UniversalMatrixC:                     contUniversalC,
+		CommitMatrices:                       s.CommitMatrices,
+		PrivateMatrixA:                       contPrivateA,
+		PrivateMatrixB:                       contPrivateB,
+		PrivateMatrixC:                       contPrivateC,
+		MatrixDigest:                         contMatrixDigest,
+	}
+
+	merklePaths = MerklePaths{
+		Leaves:            merkleObject.Leaves,
+		LeafIndexes:       merkleObject.LeafIndexes,
+		LeafSiblingHashes: merkleObject.LeafSiblingHashes,
+		AuthPaths:         merkleObject.AuthPaths,
+		Layout:            merkleLayout,
+	}
+	firstRoundPathsForCircuit = MerklePaths{
+		Leaves:            firstRoundMerkleObject.Leaves,
+		LeafIndexes:       firstRoundMerkleObject.LeafIndexes,
+		LeafSiblingHashes: firstRoundMerkleObject.LeafSiblingHashes,
+		AuthPaths:         firstRoundMerkleObject.AuthPaths,
+		Layout:            firstRoundLayout,
+	}
+
+	assignment := Circuit{
+		IO:                                   []byte(cfg.IOPattern),
+		Transcript:                           transcriptT,
+		TranscriptMode:                       s.TranscriptMode,
+		PrivateTranscript:                    privateTranscript,
+		TranscriptDigest:                     transcriptDigest,
+		PublicInputs:                         publicInputs,
+		FoldOptimisation:                     !s.NaiveFolds,
+		PoWMode:                              s.PoWMode,
+		InitialStatement:                     true,
+		DomainSize:                           domainSize,
+		BatchSize:                            len(proof_arg.FirstRoundPaths),
+		StartingDomainBackingDomainGenerator: startingDomainGen,
+		FoldingFactorArray:                   foldingFactor,
+		PowBits:                              powBits,
+		FinalPowBits:                         cfg.FinalPowBits,
+		FinalFoldingPowBits:                  cfg.FinalFoldingPowBits,
+		FinalSumcheckRounds:                  finalSumcheckRounds,
+		MVParamsNumberOfVariables:            mvParamsNumberOfVariables,
+		RoundParametersOODSamples:            oodSamples,
+		RoundParametersNumOfQueries:          numOfQueries,
+		ParamNRounds:                         nRounds,
+		FinalQueries:                         finalQueries,
+		StatementPoints:                      statementPoints,
+		StatementEvaluations:                 0,
+		LinearStatementEvaluations:           linearStatementEvaluations,
+		LinearStatementValuesAtPoints:        linearStatementValuesAtPoints,
+		MerklePaths:                          merklePaths,
+		FirstRoundPaths:                      firstRoundPathsForCircuit,
+		MerkleArity:                          arity,
+		NVars:                                cfg.NVars,
+		LogNumConstraints:                    cfg.LogNumConstraints,
+		MatrixA:                              matrixA,
+		MatrixB:                              matrixB,
+		MatrixC:                              matrixC,
+		Universal:                            s.Universal != nil,
+		UniversalMatrixA:                     universalA,
+		UniversalMatrixB:                     universalB,
+		UniversalMatrixC:                     universalC,
+		CommitMatrices:                       s.CommitMatrices,
+		PrivateMatrixA:                       privateA,
+		PrivateMatrixB:                       privateB,
+		PrivateMatrixC:                       privateC,
+		MatrixDigest:                         matrixDigestT,
+	}
+
+	if err := resolveHashes(&circuit, cfg, s); err != nil {
+		return Circuit{}, Circuit{}, err
+	}
+	assignment.MerkleHash, assignment.TranscriptHash, assignment.PoWHash = circuit.MerkleHash, circuit.TranscriptHash, circuit.PoWHash
+	assignment.LeafHash = circuit.LeafHash
+
+	return circuit, assignment, nil
+}
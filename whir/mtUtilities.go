@@ -0,0 +1,867 @@
+package whir
+
+import (
+	"fmt"
+	"math/big"
+	"math/bits"
+	"reilabs/whir-verifier-circuit/utilities"
+
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/math/uints"
+	gnark_nimue "github.com/reilabs/gnark-nimue"
+	skyscraper "github.com/reilabs/gnark-skyscraper"
+)
+
+func calculateEQ(api frontend.API, alphas []frontend.Variable, r []frontend.Variable) frontend.Variable {
+	ans := frontend.Variable(1)
+	for i, alpha := range alphas {
+		ans = api.Mul(ans, api.Add(api.Mul(alpha, r[i]), api.Mul(api.Sub(frontend.Variable(1), alpha), api.Sub(frontend.Variable(1), r[i]))))
+	}
+	return ans
+}
+
+func GetStirChallenges(
+	api frontend.API,
+	circuit Circuit,
+	arthur gnark_nimue.Arthur,
+	numQueries int,
+	domainSize int,
+	roundIndex int,
+) ([]frontend.Variable, error) {
+	foldedDomainSize := domainSize / (1 << circuit.FoldingFactorArray[roundIndex])
+	domainSizeBytes := (bits.Len(uint(foldedDomainSize*2-1)) - 1 + 7) / 8
+
+	stirQueries := make([]uints.U8, domainSizeBytes*numQueries)
+	if err := arthur.FillChallengeBytes(stirQueries); err != nil {
+		return nil, err
+	}
+
+	bitLength := bits.Len(uint(foldedDomainSize)) - 1
+
+	// The bytes of every query are big-endian and the index is their bottom
+	// bitLength bits, so only the most significant byte kept, which holds the
+	// remaining bitLength%8 bits, has to be decomposed.
+	fullBytes := bitLength / 8
+	partialBits := bitLength % 8
+	indexes := make([]frontend.Variable, numQueries)
+	for i := range numQueries {
+		query := stirQueries[i*domainSizeBytes : (i+1)*domainSizeBytes]
+		var value frontend.Variable = 0
+		if partialBits > 0 {
+			bitsOfByte := api.ToBinary(query[domainSizeBytes-fullBytes-1].Val, 8)
+			value = api.FromBinary(bitsOfByte[:partialBits]...)
+		}
+		for _, b := range query[domainSizeBytes-fullBytes:] {
+			value = api.Add(b.Val, api.Mul(value, 256))
+		}
+		indexes[i] = value
+	}
+
+	return indexes, nil
+}
+
+type MerklePaths struct {
+	Leaves            [][][]frontend.Variable
+	LeafIndexes       [][]uints.U64
+	LeafSiblingHashes [][][]uints.U8
+	AuthPaths         [][][][]uints.U8
+	// Layout holds the leaf indexes the circuit is compiled for when it
+	// hashes the shared nodes of the paths once, see WithSharedNodes.
+	Layout [][]uint64
+}
+
+// verify asserts that the leaves open the r-th paths, hashing every node
+// once when the paths have a layout.
+func (paths MerklePaths) verify(api frontend.API, uapi *uints.BinaryField[uints.U64], hasher MerkleHasher, arity int, r int, leaves [][]frontend.Variable, rootHash Digest) error {
+	if paths.Layout != nil {
+		return VerifyMerkleMultiProof(api, uapi, hasher, arity, paths.Layout[r], paths.LeafIndexes[r], leaves, paths.LeafSiblingHashes[r], paths.AuthPaths[r], rootHash)
+	}
+	return VerifyMerkleTreeProofs(api, uapi, hasher, arity, paths.LeafIndexes[r], leaves, paths.LeafSiblingHashes[r], paths.AuthPaths[r], rootHash)
+}
+
+// isSubset asserts that the challenge indexes are among the r-th leaf
+// indexes, which have to be sorted and, unless padded, distinct.
+func (paths MerklePaths) isSubset(api frontend.API, uapi *uints.BinaryField[uints.U64], arity int, r int, indexes []frontend.Variable, padded bool) error {
+	nbBits := 0
+	if len(paths.AuthPaths[r]) > 0 {
+		nbBits = (len(paths.AuthPaths[r][0]) + 1) * bits.TrailingZeros(uint(arity))
+	}
+	return utilities.IsSubset(api, uapi, indexes, paths.LeafIndexes[r], nbBits, padded)
+}
+
+type Circuit struct {
+	// Inputs
+	DomainSize                           int
+	StartingDomainBackingDomainGenerator frontend.Variable `gnark:"-"`
+	FoldingFactorArray                   []int
+	FinalSumcheckRounds                  int
+	ParamNRounds                         int
+	MVParamsNumberOfVariables            int
+	RoundParametersOODSamples            []int
+	RoundParametersNumOfQueries          []int
+	InitialStatement                     bool
+	FoldOptimisation                     bool
+	PowBits                              []int
+	FinalPowBits                         int
+	FinalFoldingPowBits                  int
+	PoWMode                              utilities.PoWMode
+	MerkleHash                           Hash
+	TranscriptHash                       Hash
+	PoWHash                              Hash
+	LeafHash                             LeafHash
+	MerkleArity                          int
+	FinalQueries                         int
+	BatchSize                            int
+	MerklePaths                          MerklePaths
+	FirstRoundPaths                      MerklePaths
+	StatementPoints                      [][]frontend.Variable
+	StatementEvaluations                 int
+	LinearStatementValuesAtPoints        []frontend.Variable
+	LinearStatementEvaluations           []frontend.Variable
+	NVars                                int
+	LogNumConstraints                    int
+	MatrixA                              []MatrixCell
+	MatrixB                              []MatrixCell
+	MatrixC                              []MatrixCell
+	// Universal replaces the constant matrices with the public, padded
+	// UniversalMatrix inputs, see WithUniversal.
+	Universal        bool
+	UniversalMatrixA []MatrixEntry `gnark:",public"`
+	UniversalMatrixB []MatrixEntry `gnark:",public"`
+	UniversalMatrixC []MatrixEntry `gnark:",public"`
+	// CommitMatrices moves the universal matrices to PrivateMatrix, committed
+	// to by MatrixDigest, see WithCommittedMatrices.
+	CommitMatrices bool
+	PrivateMatrixA []MatrixEntry
+	PrivateMatrixB []MatrixEntry
+	PrivateMatrixC []MatrixEntry
+	MatrixDigest   []frontend.Variable `gnark:",public"`
+	// UniversalLogVars bounds the columns of the universal matrices, see
+	// checkMatrixDigest.
+	UniversalLogVars int
+	// Shapes are the verifiers of the universal circuit, one for every
+	// instance size it admits, of which ShapeSelector picks the one the
+	// proof is verified with. The shapes leave the transcript, the matrices
+	// and the public inputs to the circuit holding them, of which they read
+	// the first TranscriptLen transcript bytes, see Circuit.shape.
+	Shapes        []Circuit
+	ShapeSelector []frontend.Variable
+	TranscriptLen int
+	// Public Input
+	IO         []byte
+	Transcript []uints.U8 `gnark:",public"`
+	// TranscriptMode decides whether Transcript is public or whether the
+	// transcript is PrivateTranscript, committed to by TranscriptDigest.
+	TranscriptMode    TranscriptMode
+	PrivateTranscript []uints.U8
+	TranscriptDigest  []frontend.Variable `gnark:",public"`
+	// PublicInputs are the R1CS public inputs, the witness entries following
+	// the constant one, given next to the proof, see WithPublicInputs. They
+	// are bound to the committed witness by the last linear statement, see
+	// bindPublicInputs.
+	PublicInputs []frontend.Variable `gnark:",public"`
+}
+
+type MainRoundData struct {
+	OODPoints             [][]frontend.Variable
+	StirChallengesPoints  [][]frontend.Variable
+	CombinationRandomness [][]frontend.Variable
+}
+
+func generateEmptyMainRoundData(circuit *Circuit) MainRoundData {
+	return MainRoundData{
+		OODPoints:             make([][]frontend.Variable, len(circuit.RoundParametersOODSamples)),
+		StirChallengesPoints:  make([][]frontend.Variable, len(circuit.RoundParametersOODSamples)),
+		CombinationRandomness: make([][]frontend.Variable, len(circuit.RoundParametersOODSamples)),
+	}
+}
+
+// VerifyMerkleTreeProofs asserts that the leaves open a tree of the given
+// arity, a power of two, at the leaf indexes. Every level of the paths holds
+// the arity-1 siblings of the node, concatenated in the order of the children.
+func VerifyMerkleTreeProofs(api frontend.API, uapi *uints.BinaryField[uints.U64], hasher MerkleHasher, arity int, leafIndexes []uints.U64, leaves [][]frontend.Variable, leafSiblingHashes [][]uints.U8, authPaths [][][]uints.U8, rootHash Digest) error {
+	numOfLeavesProved := len(leaves)
+	digitBits := bits.TrailingZeros(uint(arity))
+
+	for i := range numOfLeavesProved {
+		treeHeight := len(authPaths[i]) + 1
+		leafIndexBits := api.ToBinary(uapi.ToValue(leafIndexes[i]), treeHeight*digitBits)
+		currentHash, err := hasher.HashLeaf(leaves[i])
+		if err != nil {
+			return err
+		}
+
+		for level := range treeHeight {
+			siblingBytes := leafSiblingHashes[i]
+			if level > 0 {
+				siblingBytes = authPaths[i][level-1]
+			}
+			siblings := make([]Digest, arity-1)
+			for k := range siblings {
+				siblings[k] = hasher.Digest(siblingBytes[k*len(siblingBytes)/(arity-1) : (k+1)*len(siblingBytes)/(arity-1)])
+			}
+
+			isDigit := digitIndicators(api, leafIndexBits[level*digitBits:(level+1)*digitBits])
+			children := make([]Digest, arity)
+			afterDigit := frontend.Variable(0)
+			for k := range children {
+				// The node sits at its digit, preceded by the siblings
+				// before it and followed by the ones after it.
+				children[k] = make(Digest, len(currentHash))
+				for j := range currentHash {
+					var sibling frontend.Variable
+					switch k {
+					case 0:
+						sibling = siblings[0][j]
+					case arity - 1:
+						sibling = siblings[arity-2][j]
+					default:
+						sibling = api.Select(afterDigit, siblings[k-1][j], siblings[k][j])
+					}
+					children[k][j] = api.Select(isDigit[k], currentHash[j], sibling)
+				}
+				afterDigit = api.Add(afterDigit, isDigit[k])
+			}
+
+			currentHash = hasher.Compress(children...)
+		}
+		for j := range currentHash {
+			api.AssertIsEqual(currentHash[j], rootHash[j])
+		}
+	}
+	return nil
+}
+
+// VerifyMerkleMultiProof asserts the same as VerifyMerkleTreeProofs for
+// leaves opened at the given constant indexes, hashing every node of the
+// tree the paths go through once. Each node takes its missing children from
+// the path of the first leaf below it, and the paths of the other leaves
+// below it are asserted to agree.
+func VerifyMerkleMultiProof(api frontend.API, uapi *uints.BinaryField[uints.U64], hasher MerkleHasher, arity int, layout []uint64, leafIndexes []uints.U64, leaves [][]frontend.Variable, leafSiblingHashes [][]uints.U8, authPaths [][][]uints.U8, rootHash Digest) error {
+	if len(layout) != len(leaves) {
+		return fmt.Errorf("layout has %d leaves, the paths open %d", len(layout), len(leaves))
+	}
+	if len(leaves) == 0 {
+		return nil
+	}
+	digitBits := bits.TrailingZeros(uint(arity))
+	treeHeight := len(authPaths[0]) + 1
+
+	// nodes maps the index of every node of the current level to its hash.
+	nodes := make(map[uint64]Digest, len(leaves))
+	for i := range leaves {
+		api.AssertIsEqual(uapi.ToValue(leafIndexes[i]), layout[i])
+		if layout[i]>>(treeHeight*digitBits) != 0 {
+			return fmt.Errorf("leaf index %d does not fit a tree of height %d and arity %d", layout[i], treeHeight, arity)
+		}
+		leafHash, err := hasher.HashLeaf(leaves[i])
+		if err != nil {
+			return err
+		}
+		if node, ok := nodes[layout[i]]; ok {
+			assertDigestsEqual(api, node, leafHash)
+		} else {
+			nodes[layout[i]] = leafHash
+		}
+	}
+
+	for level := range treeHeight {
+		// parents lists the parents of the level in the order the leaves
+		// reach them, with the leaves below each one.
+		var parents []uint64
+		below := make(map[uint64][]int)
+		for i := range layout {
+			parent := layout[i] >> ((level + 1) * digitBits)
+			if _, ok := below[parent]; !ok {
+				parents = append(parents, parent)
+			}
+			below[parent] = append(below[parent], i)
+		}
+
+		next := make(map[uint64]Digest, len(parents))
+		for _, parent := range parents {
+			children := make([]Digest, arity)
+			siblings := make([][]Digest, len(below[parent]))
+			for n, i := range below[parent] {
+				siblingBytes := leafSiblingHashes[i]
+				if level > 0 {
+					siblingBytes = authPaths[i][level-1]
+				}
+				siblings[n] = make([]Digest, arity-1)
+				for k := range siblings[n] {
+					siblings[n][k] = hasher.Digest(siblingBytes[k*len(siblingBytes)/(arity-1) : (k+1)*len(siblingBytes)/(arity-1)])
+				}
+			}
+			for k := range arity {
+				children[k] = nodes[parent<<digitBits|uint64(k)]
+			}
+			for n, i := range below[parent] {
+				digit := int(layout[i] >> (level * digitBits) & uint64(arity-1))
+				for k := range siblings[n] {
+					child := k
+					if k >= digit {
+						child++
+					}
+					if children[child] == nil {
+						children[child] = siblings[n][k]
+					} else {
+						assertDigestsEqual(api, children[child], siblings[n][k])
+					}
+				}
+			}
+			next[parent] = hasher.Compress(children...)
+		}
+		nodes = next
+	}
+
+	assertDigestsEqual(api, nodes[0], rootHash)
+	return nil
+}
+
+func assertDigestsEqual(api frontend.API, a, b Digest) {
+	for j := range a {
+		api.AssertIsEqual(a[j], b[j])
+	}
+}
+
+// digitIndicators returns, for every value of the little-endian bits, whether
+// the bits take that value.
+func digitIndicators(api frontend.API, digit []frontend.Variable) []frontend.Variable {
+	res := []frontend.Variable{1}
+	for _, bit := range digit {
+		next := make([]frontend.Variable, 2*len(res))
+		for k := range res {
+			next[len(res)+k] = api.Mul(res[k], bit)
+			next[k] = api.Sub(res[k], next[len(res)+k])
+		}
+		res = next
+	}
+	return res
+}
+
+type InitialSumcheckData struct {
+	InitialOODQueries            []frontend.Variable
+	InitialCombinationRandomness []frontend.Variable
+}
+
+func initialSumcheck(
+	api frontend.API,
+	circuit *Circuit,
+	arthur gnark_nimue.Arthur,
+	initialOODQueries []frontend.Variable,
+	initialOODAnswers []frontend.Variable,
+) (InitialSumcheckData, frontend.Variable, []frontend.Variable, error) {
+
+	initialCombinationRandomness, err := GenerateCombinationRandomness(api, arthur, len(initialOODAnswers)+len(circuit.LinearStatementEvaluations))
+	if err != nil {
+		return InitialSumcheckData{}, nil, nil, err
+	}
+
+	OODAnswersAndStatmentEvaluations := append(initialOODAnswers, circuit.LinearStatementEvaluations...)
+
+	lastEval := utilities.DotProduct(api, initialCombinationRandomness, OODAnswersAndStatmentEvaluations)
+	initialSumcheckFoldingRandomness, lastEval, err := runSumcheckRounds(api, lastEval, arthur, circuit.FoldingFactorArray[0], 2)
+	if err != nil {
+		return InitialSumcheckData{}, nil, nil, err
+	}
+
+	return InitialSumcheckData{
+		InitialOODQueries:            initialOODQueries,
+		InitialCombinationRandomness: initialCombinationRandomness,
+	}, lastEval, initialSumcheckFoldingRandomness, nil
+}
+
+func FillInOODPointsAndAnswers(numberOfOODPoints int, arthur gnark_nimue.Arthur) ([]frontend.Variable, []frontend.Variable, error) {
+	if numberOfOODPoints == 0 {
+		return []frontend.Variable{}, []frontend.Variable{}, nil
+	}
+	oodPoints := make([]frontend.Variable, numberOfOODPoints)
+	oodAnswers := make([]frontend.Variable, numberOfOODPoints)
+
+	if err := arthur.FillChallengeScalars(oodPoints); err != nil {
+		return nil, nil, err
+	}
+
+	if err := arthur.FillNextScalars(oodAnswers); err != nil {
+		return nil, nil, err
+	}
+
+	return oodPoints, oodAnswers, nil
+}
+
+func RunPoW(pow utilities.PoWHasher, arthur gnark_nimue.Arthur, difficulty int, mode utilities.PoWMode) error {
+	if difficulty > 0 {
+		_, _, err := utilities.PoW(pow, arthur, difficulty, mode)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func GenerateStirChallengePoints(api frontend.API, arthur gnark_nimue.Arthur, NQueries int, leafIndexes []uints.U64, domainSize int, circuit *Circuit, uapi *uints.BinaryField[uints.U64], expDomainGenerator frontend.Variable, roundIndex int) ([]frontend.Variable, error) {
+	finalIndexes, err := GetStirChallenges(api, *circuit, arthur, NQueries, domainSize, roundIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	err = circuit.MerklePaths.isSubset(api, uapi, circuit.MerkleArity, len(circuit.MerklePaths.LeafIndexes)-1, finalIndexes, circuit.Universal)
+	if err != nil {
+		return nil, err
+	}
+
+	finalRandomnessPoints := make([]frontend.Variable, len(leafIndexes))
+
+	indexBits := bits.Len(uint(domainSize>>circuit.FoldingFactorArray[roundIndex])) - 1
+	for index := range leafIndexes {
+		finalRandomnessPoints[index] = utilities.FixedBaseExponent(api, expDomainGenerator, uapi.ToValue(leafIndexes[index]), indexBits)
+	}
+
+	return finalRandomnessPoints, nil
+}
+
+func GenerateCombinationRandomness(api frontend.API, arthur gnark_nimue.Arthur, randomnessLength int) ([]frontend.Variable, error) {
+	combRandomnessGen := make([]frontend.Variable, 1)
+	if err := arthur.FillChallengeScalars(combRandomnessGen); err != nil {
+		return nil, err
+	}
+
+	combinationRandomness := utilities.ExpandRandomness(api, combRandomnessGen[0], randomnessLength)
+
+	return combinationRandomness, nil
+
+}
+
+func oodData(api frontend.API, oodAnswers [][]frontend.Variable, batchingRandomness frontend.Variable) []frontend.Variable {
+	if len(oodAnswers) == 0 {
+		return []frontend.Variable{}
+	}
+
+	result := make([]frontend.Variable, len(oodAnswers[0]))
+	for i, v := range oodAnswers[0] {
+		result[i] = v
+	}
+
+	multiplier := batchingRandomness
+
+	for round := 1; round < len(oodAnswers); round++ {
+		thisRound := oodAnswers[round]
+		currentMultiplier := multiplier
+		multiplier = api.Mul(multiplier, batchingRandomness)
+
+		api.AssertIsEqual(len(thisRound), len(result))
+
+		for i := range result {
+			term := api.Mul(thisRound[i], currentMultiplier)
+			result[i] = api.Add(result[i], term)
+		}
+	}
+
+	return result
+}
+
+// runSumcheckRounds verifies foldingFactor rounds of the WHIR sumcheck, whose
+// round polynomials are sent as their evaluations at 0, 1, ..., polynomialDegree.
+func runSumcheckRounds(
+	api frontend.API,
+	lastEval frontend.Variable,
+	arthur gnark_nimue.Arthur,
+	foldingFactor int,
+	polynomialDegree int,
+) ([]frontend.Variable, frontend.Variable, error) {
+	return utilities.Sumcheck(api, arthur, lastEval, foldingFactor, polynomialDegree, utilities.SumcheckEvaluations)
+}
+
+func ComputeWPoly(
+	api frontend.API,
+	circuit *Circuit,
+	initialOODQueries []frontend.Variable,
+	initialSumcheckData InitialSumcheckData,
+	mainRoundData MainRoundData,
+	sp_rand []frontend.Variable,
+	totalFoldingRandomness []frontend.Variable,
+) frontend.Variable {
+	foldingRandomnessReversed := utilities.Reverse(totalFoldingRandomness)
+
+	numberVars := circuit.MVParamsNumberOfVariables
+
+	value := frontend.Variable(0)
+	for j := range initialOODQueries {
+		value = api.Add(value, api.Mul(initialSumcheckData.InitialCombinationRandomness[j], utilities.EqPolyOutside(api, utilities.ExpandFromUnivariate(api, initialOODQueries[j], numberVars), foldingRandomnessReversed)))
+	}
+
+	var matrixExtensionEvals []frontend.Variable
+	if circuit.Universal {
+		matrixExtensionEvals = evaluateUniversalMatrixExtension(api, circuit, sp_rand, foldingRandomnessReversed)
+	} else {
+		matrixExtensionEvals = evaluateR1CSMatrixExtension(api, circuit, sp_rand, foldingRandomnessReversed)
+	}
+	if len(circuit.PublicInputs) > 0 {
+		matrixExtensionEvals = append(matrixExtensionEvals, evaluatePublicInputWeight(api, initialOODQueries[0], len(circuit.PublicInputs)+1, foldingRandomnessReversed))
+	}
+
+	for j := range circuit.LinearStatementValuesAtPoints {
+		value = api.Add(value, api.Mul(initialSumcheckData.InitialCombinationRandomness[len(initialSumcheckData.InitialOODQueries)+j], matrixExtensionEvals[j]))
+	}
+
+	for r := range mainRoundData.OODPoints {
+		numberVars -= circuit.FoldingFactorArray[r]
+		newTmpArr := append(mainRoundData.OODPoints[r], mainRoundData.StirChallengesPoints[r]...)
+
+		sumOfClaims := frontend.Variable(0)
+		for i := range newTmpArr {
+			point := utilities.ExpandFromUnivariate(api, newTmpArr[i], numberVars)
+			sumOfClaims = api.Add(sumOfClaims, api.Mul(utilities.EqPolyOutside(api, point, foldingRandomnessReversed[0:numberVars]), mainRoundData.CombinationRandomness[r][i]))
+		}
+		value = api.Add(value, sumOfClaims)
+	}
+
+	return value
+}
+
+func ComputeFoldsHelped(api frontend.API, circuit *Circuit, initialSumcheckFoldingRandomness []frontend.Variable, mainRoundFoldingRandomness [][]frontend.Variable) [][]frontend.Variable {
+	foldingRandomness := append([][]frontend.Variable{initialSumcheckFoldingRandomness}, mainRoundFoldingRandomness...)
+	result := make([][]frontend.Variable, len(circuit.MerklePaths.Leaves))
+
+	for i := range len(circuit.MerklePaths.Leaves) {
+		result[i] = make([]frontend.Variable, len(circuit.MerklePaths.Leaves[i]))
+		for j := range circuit.MerklePaths.Leaves[i] {
+			result[i][j] = utilities.MultivarPoly(circuit.MerklePaths.Leaves[i][j], foldingRandomness[i], api)
+		}
+	}
+
+	return result
+}
+
+// ComputeFoldsFull is ComputeFoldsHelped for leaves holding the evaluations of
+// the function over their coset rather than the coefficients of its fold. The
+// leaves of MerklePaths round i lie on the domain of round i+1.
+func ComputeFoldsFull(api frontend.API, uapi *uints.BinaryField[uints.U64], circuit *Circuit, initialSumcheckFoldingRandomness []frontend.Variable, mainRoundFoldingRandomness [][]frontend.Variable) [][]frontend.Variable {
+	foldingRandomness := append([][]frontend.Variable{initialSumcheckFoldingRandomness}, mainRoundFoldingRandomness...)
+	result := make([][]frontend.Variable, len(circuit.MerklePaths.Leaves))
+
+	for i := range len(circuit.MerklePaths.Leaves) {
+		domainGenInv, domainSize := roundDomain(api, circuit, i+1)
+		result[i] = computeFoldsNaive(api, uapi, circuit.MerklePaths.Leaves[i], circuit.MerklePaths.LeafIndexes[i], foldingRandomness[i], domainGenInv, domainSize)
+	}
+
+	return result
+}
+
+func ComputeFolds(api frontend.API, uapi *uints.BinaryField[uints.U64], circuit *Circuit, initialSumcheckFoldingRandomness []frontend.Variable, mainRoundFoldingRandomness [][]frontend.Variable) [][]frontend.Variable {
+	if circuit.FoldOptimisation {
+		return ComputeFoldsHelped(api, circuit, initialSumcheckFoldingRandomness, mainRoundFoldingRandomness)
+	} else {
+		return ComputeFoldsFull(api, uapi, circuit, initialSumcheckFoldingRandomness, mainRoundFoldingRandomness)
+	}
+}
+
+// foldLeaves folds the leaves opened on the domain of the given round. With
+// FoldOptimisation the prover sends the coefficients of the fold of every
+// coset, and otherwise the evaluations over the coset (WHIR's
+// FoldType::Naive).
+func foldLeaves(api frontend.API, uapi *uints.BinaryField[uints.U64], circuit *Circuit, leaves [][]frontend.Variable, leafIndexes []uints.U64, foldingRandomness []frontend.Variable, round int) []frontend.Variable {
+	if circuit.FoldOptimisation {
+		return computeFold(leaves, foldingRandomness, api)
+	}
+	domainGenInv, domainSize := roundDomain(api, circuit, round)
+	return computeFoldsNaive(api, uapi, leaves, leafIndexes, foldingRandomness, domainGenInv, domainSize)
+}
+
+// roundDomain returns the inverse of the generator and the size of the
+// evaluation domain of the given round, which halves in every round.
+func roundDomain(api frontend.API, circuit *Circuit, round int) (frontend.Variable, int) {
+	domainGenInv := api.Inverse(circuit.StartingDomainBackingDomainGenerator)
+	for range round {
+		domainGenInv = api.Mul(domainGenInv, domainGenInv)
+	}
+	return domainGenInv, circuit.DomainSize >> round
+}
+
+// computeFoldsNaive folds the leaves at leafIndexes, each holding the
+// evaluations of a function over the coset w^index·<w^(domainSize/2^k)> of
+// the domain generated by w.
+func computeFoldsNaive(api frontend.API, uapi *uints.BinaryField[uints.U64], leaves [][]frontend.Variable, leafIndexes []uints.U64, foldingRandomness []frontend.Variable, domainGenInv frontend.Variable, domainSize int) []frontend.Variable {
+	cosetGenInv := domainGenInv
+	for size := domainSize; size > 1<<len(foldingRandomness); size /= 2 {
+		cosetGenInv = api.Mul(cosetGenInv, cosetGenInv)
+	}
+
+	indexBits := bits.Len(uint(domainSize>>len(foldingRandomness))) - 1
+	computedFold := make([]frontend.Variable, len(leaves))
+	for j := range leaves {
+		cosetOffsetInv := utilities.FixedBaseExponent(api, domainGenInv, uapi.ToValue(leafIndexes[j]), indexBits)
+		computedFold[j] = computeFoldNaive(api, leaves[j], foldingRandomness, cosetOffsetInv, cosetGenInv)
+	}
+	return computedFold
+}
+
+// computeFoldNaive folds the evaluations of f over the coset offset·<g>,
+// halving the coset once per folding variable: with f(x) = fe(x^2) + x·fo(x^2),
+// the evaluations at x and -x give fe(x^2) + r·fo(x^2) as (left + r·right)/2,
+// where left = f(x) + f(-x) and right = (f(x) - f(-x))/x. The first step
+// folds the variable that MultivarPoly assigns to foldingRandomness[0].
+func computeFoldNaive(api frontend.API, answers []frontend.Variable, foldingRandomness []frontend.Variable, cosetOffsetInv frontend.Variable, cosetGenInv frontend.Variable) frontend.Variable {
+	for _, r := range foldingRandomness {
+		half := len(answers) / 2
+		folded := make([]frontend.Variable, half)
+		pointInv := cosetOffsetInv
+		for i := range half {
+			left := api.Add(answers[i], answers[i+half])
+			right := api.Mul(pointInv, api.Sub(answers[i], answers[i+half]))
+			folded[i] = api.Div(api.Add(left, api.Mul(r, right)), 2)
+			pointInv = api.Mul(pointInv, cosetGenInv)
+		}
+		answers = folded
+		cosetOffsetInv = api.Mul(cosetOffsetInv, cosetOffsetInv)
+		cosetGenInv = api.Mul(cosetGenInv, cosetGenInv)
+	}
+	return answers[0]
+}
+
+func SumcheckForR1CSIOP(api frontend.API, arthur gnark_nimue.Arthur, circuit *Circuit) ([]frontend.Variable, []frontend.Variable, frontend.Variable, error) {
+	t_rand := make([]frontend.Variable, circuit.LogNumConstraints)
+	err := arthur.FillChallengeScalars(t_rand)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// The R1CS sumcheck sends its cubic round polynomials as coefficients.
+	sp_rand, savedValForSumcheck, err := utilities.Sumcheck(api, arthur, 0, circuit.LogNumConstraints, 3, utilities.SumcheckCoefficients)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return t_rand, sp_rand, savedValForSumcheck, nil
+}
+
+func ValidateFirstRound(api frontend.API, circuit *Circuit, arthur gnark_nimue.Arthur, uapi *uints.BinaryField[uints.U64], hasher MerkleHasher, batchSizeLen frontend.Variable, rootHashes []Digest, batchingRandomness frontend.Variable, stirChallengeIndexes []frontend.Variable, roundAnswers [][]frontend.Variable) error {
+
+	for i := range circuit.FirstRoundPaths.Leaves {
+		err := circuit.FirstRoundPaths.verify(api, uapi, hasher, circuit.MerkleArity, i, circuit.FirstRoundPaths.Leaves[i], rootHashes[i])
+		if err != nil {
+			return err
+		}
+		err = circuit.FirstRoundPaths.isSubset(api, uapi, circuit.MerkleArity, i, stirChallengeIndexes, circuit.Universal)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func parseBatchedCommitment(api frontend.API, arthur gnark_nimue.Arthur, circuit *Circuit, hasher MerkleHasher) ([]Digest, frontend.Variable, []frontend.Variable, [][]frontend.Variable, error) {
+
+	rootHashes := make([]Digest, circuit.BatchSize)
+	for i := range circuit.BatchSize {
+		rootHash, err := hasher.ReadRoot(arthur)
+		if err != nil {
+			return nil, 0, []frontend.Variable{}, [][]frontend.Variable{}, err
+		}
+		rootHashes[i] = rootHash
+	}
+
+	oodPoints := make([]frontend.Variable, 1)
+	oodAnswers := make([][]frontend.Variable, circuit.BatchSize)
+
+	if err := arthur.FillChallengeScalars(oodPoints); err != nil {
+		return nil, nil, nil, nil, err
+	}
+	for i := range circuit.BatchSize {
+		oodAnswer := make([]frontend.Variable, 1)
+
+		if err := arthur.FillNextScalars(oodAnswer); err != nil {
+			return nil, nil, nil, nil, err
+		}
+		oodAnswers[i] = oodAnswer
+	}
+
+	batchingRandomness := make([]frontend.Variable, 1)
+	if err := arthur.FillChallengeScalars(batchingRandomness); err != nil {
+		return nil, 0, []frontend.Variable{}, [][]frontend.Variable{}, err
+	}
+	return rootHashes, batchingRandomness[0], oodPoints, oodAnswers, nil
+}
+
+func generateFinalCoefficientsAndRandomnessPoints(api frontend.API, arthur gnark_nimue.Arthur, circuit *Circuit, uapi *uints.BinaryField[uints.U64], pow utilities.PoWHasher, domainSize int, expDomainGenerator frontend.Variable) ([]frontend.Variable, []frontend.Variable, error) {
+	finalCoefficients := make([]frontend.Variable, 1<<circuit.FinalSumcheckRounds)
+	if err := arthur.FillNextScalars(finalCoefficients); err != nil {
+		return nil, nil, err
+	}
+	finalRandomnessPoints, err := GenerateStirChallengePoints(api, arthur, circuit.FinalQueries, circuit.MerklePaths.LeafIndexes[len(circuit.MerklePaths.LeafIndexes)-1], domainSize, circuit, uapi, expDomainGenerator, len(circuit.FoldingFactorArray)-1)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := RunPoW(pow, arthur, circuit.FinalPowBits, circuit.PoWMode); err != nil {
+		return nil, nil, err
+	}
+	return finalCoefficients, finalRandomnessPoints, nil
+}
+
+func initializeComponents(api frontend.API, circuit *Circuit) (*skyscraper.Skyscraper, gnark_nimue.Arthur, *uints.BinaryField[uints.U64], hashes, error) {
+	var sc *skyscraper.Skyscraper
+	if usesSkyscraper(circuit) {
+		sc = skyscraper.NewSkyscraper(api, 2)
+	}
+	arthur, err := newArthur(api, sc, circuit)
+	if err != nil {
+		return nil, nil, nil, hashes{}, err
+	}
+	uapi, err := uints.New[uints.U64](api)
+	if err != nil {
+		return nil, nil, nil, hashes{}, err
+	}
+	h, err := newHashes(api, uapi, sc, circuit)
+	if err != nil {
+		return nil, nil, nil, hashes{}, err
+	}
+	return sc, arthur, uapi, h, nil
+}
+
+func computeFold(leaves [][]frontend.Variable, foldingRandomness []frontend.Variable, api frontend.API) []frontend.Variable {
+	computedFold := make([]frontend.Variable, len(leaves))
+	for j := range leaves {
+		computedFold[j] = utilities.MultivarPoly(leaves[j], foldingRandomness, api)
+	}
+	return computedFold
+}
+
+func combineFirstRoundLeaves(api frontend.API, firstRoundPath [][][]frontend.Variable, combinationRandomness frontend.Variable) [][]frontend.Variable {
+	combinedFirstRound := firstRoundPath[0]
+
+	multiplier := combinationRandomness
+	for i := 1; i < len(firstRoundPath); i++ {
+		for j := range firstRoundPath[i] {
+			for k := range firstRoundPath[i][j] {
+				combinedFirstRound[j][k] = api.Add(combinedFirstRound[j][k], api.Mul(multiplier, firstRoundPath[i][j][k]))
+			}
+		}
+		multiplier = api.Mul(multiplier, combinationRandomness)
+	}
+	return combinedFirstRound
+}
+
+func calculateShiftValue(oodAnswers []frontend.Variable, combinationRandomness []frontend.Variable, computedFold []frontend.Variable, api frontend.API) frontend.Variable {
+	return utilities.DotProduct(api, append(oodAnswers, computedFold...), combinationRandomness)
+}
+
+func mustBigInt(s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		panic("invalid big.Int string: " + s)
+	}
+	return n
+}
+
+// evaluateR1CSMatrixExtension evaluates the multilinear extensions of the
+// constant matrices at (rowRand, colRand) without materialising the eq tables
+// over all rows and columns. eq(r, i) factors into the eq tables of the high
+// and the low half of r, which have 2^(n/2) entries each, and the entries are
+// grouped by row: the column sum of every row is a linear combination of
+// constants, so it costs no constraints, and only the rows and the distinct
+// columns cost a multiplication each.
+func evaluateR1CSMatrixExtension(api frontend.API, circuit *Circuit, rowRand []frontend.Variable, colRand []frontend.Variable) []frontend.Variable {
+	rowHi, rowLo, rowLoBits := splitEQOverBooleanHypercube(api, rowRand)
+	colHi, colLo, colLoBits := splitEQOverBooleanHypercube(api, colRand)
+
+	colEval := make(map[int]frontend.Variable)
+	column := func(j int) frontend.Variable {
+		if _, ok := colEval[j]; !ok {
+			colEval[j] = api.Mul(colHi[j>>colLoBits], colLo[j&(1<<colLoBits-1)])
+		}
+		return colEval[j]
+	}
+
+	evaluate := func(matrix []MatrixCell) frontend.Variable {
+		var rows []int
+		rowSums := make(map[int]frontend.Variable)
+		for _, cell := range matrix {
+			if _, ok := rowSums[cell.row]; !ok {
+				rows = append(rows, cell.row)
+				rowSums[cell.row] = frontend.Variable(0)
+			}
+			rowSums[cell.row] = api.Add(rowSums[cell.row], api.Mul(cell.value, column(cell.column)))
+		}
+
+		var his []int
+		hiSums := make(map[int]frontend.Variable)
+		for _, row := range rows {
+			hi := row >> rowLoBits
+			if _, ok := hiSums[hi]; !ok {
+				his = append(his, hi)
+				hiSums[hi] = frontend.Variable(0)
+			}
+			hiSums[hi] = api.Add(hiSums[hi], api.Mul(rowLo[row&(1<<rowLoBits-1)], rowSums[row]))
+		}
+
+		ans := frontend.Variable(0)
+		for _, hi := range his {
+			ans = api.Add(ans, api.Mul(rowHi[hi], hiSums[hi]))
+		}
+		return ans
+	}
+
+	return []frontend.Variable{evaluate(circuit.MatrixA), evaluate(circuit.MatrixB), evaluate(circuit.MatrixC)}
+}
+
+// splitEQOverBooleanHypercube returns the eq tables of the high and the low
+// half of r, so that eq(r, i) = hi[i >> loBits] * lo[i & (1<<loBits - 1)].
+func splitEQOverBooleanHypercube(api frontend.API, r []frontend.Variable) (hi []frontend.Variable, lo []frontend.Variable, loBits int) {
+	half := len(r) / 2
+	return calculateEQOverBooleanHypercube(api, r[:half]), calculateEQOverBooleanHypercube(api, r[half:]), len(r) - half
+}
+
+// bindPublicInputs asserts that the last linear statement is the evaluation
+// of the witness prefix (1, PublicInputs...) against the powers of gamma, so
+// that the public inputs are the ones committed to by the WHIR proof.
+func bindPublicInputs(api frontend.API, circuit *Circuit, gamma frontend.Variable) {
+	expected := frontend.Variable(1)
+	power := frontend.Variable(1)
+	for _, publicInput := range circuit.PublicInputs {
+		power = api.Mul(power, gamma)
+		expected = api.Add(expected, api.Mul(power, publicInput))
+	}
+	api.AssertIsEqual(expected, circuit.LinearStatementEvaluations[len(circuit.LinearStatementEvaluations)-1])
+}
+
+// evaluatePublicInputWeight evaluates the weight of the public input statement,
+// sum of gamma^i * eq(i, r) over the first n witness positions, at r.
+func evaluatePublicInputWeight(api frontend.API, gamma frontend.Variable, n int, r []frontend.Variable) frontend.Variable {
+	ans := frontend.Variable(0)
+	power := frontend.Variable(1)
+	for i := range n {
+		indexBits := make([]frontend.Variable, len(r))
+		for j := range r {
+			indexBits[j] = (i >> (len(r) - 1 - j)) & 1
+		}
+		ans = api.Add(ans, api.Mul(power, calculateEQ(api, indexBits, r)))
+		power = api.Mul(power, gamma)
+	}
+	return ans
+}
+
+func calculateEQOverBooleanHypercube(api frontend.API, r []frontend.Variable) []frontend.Variable {
+	ans := []frontend.Variable{frontend.Variable(1)}
+
+	for i := len(r) - 1; i >= 0; i-- {
+		x := r[i]
+		left := make([]frontend.Variable, len(ans))
+		right := make([]frontend.Variable, len(ans))
+
+		for j, y := range ans {
+			right[j] = api.Mul(y, x)
+			left[j] = api.Sub(y, right[j])
+		}
+
+		ans = append(left, right...)
+	}
+
+	return ans
+}
+
+type MatrixCell struct {
+	row    int
+	column int
+	value  *big.Int
+}
@@ -0,0 +1,388 @@
+package whir
+
+import (
+	"fmt"
+	"math/bits"
+	"reilabs/whir-verifier-circuit/native"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr/fft"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/std/math/uints"
+	"github.com/consensys/gnark/test"
+	gnark_nimue "github.com/reilabs/gnark-nimue"
+	skyscraper "github.com/reilabs/gnark-skyscraper"
+)
+
+// bindingCircuit binds public inputs to a witness statement claim, as the
+// verifier circuit does with the last linear statement.
+type bindingCircuit struct {
+	PublicInputs []frontend.Variable `gnark:",public"`
+	Gamma        frontend.Variable
+	Claim        frontend.Variable
+}
+
+func (c *bindingCircuit) Define(api frontend.API) error {
+	circuit := &Circuit{
+		PublicInputs:               c.PublicInputs,
+		LinearStatementEvaluations: []frontend.Variable{0, 0, 0, c.Claim},
+	}
+	bindPublicInputs(api, circuit, c.Gamma)
+	return nil
+}
+
+func TestPublicInputsBoundToStatement(t *testing.T) {
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &bindingCircuit{PublicInputs: make([]frontend.Variable, 2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, vk, err := groth16.Setup(ccs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1 + 3·5 + 3²·7
+	assignment := &bindingCircuit{PublicInputs: []frontend.Variable{5, 7}, Gamma: 3, Claim: 79}
+	witness, err := frontend.NewWitness(assignment, ecc.BN254.ScalarField())
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := groth16.Prove(ccs, pk, witness)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		publicInputs []frontend.Variable
+		valid        bool
+	}{
+		{[]frontend.Variable{5, 7}, true},
+		{[]frontend.Variable{5, 8}, false},
+		{[]frontend.Variable{7, 5}, false},
+	} {
+		publicWitness, err := frontend.NewWitness(&bindingCircuit{PublicInputs: tc.publicInputs}, ecc.BN254.ScalarField(), frontend.PublicOnly())
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = groth16.Verify(proof, vk, publicWitness)
+		if tc.valid && err != nil {
+			t.Errorf("public inputs %v are rejected: %v", tc.publicInputs, err)
+		}
+		if !tc.valid && err == nil {
+			t.Errorf("public inputs %v are accepted for a statement on %v", tc.publicInputs, assignment.PublicInputs)
+		}
+	}
+}
+
+// challengeArthur squeezes the given challenge bytes.
+type challengeArthur struct {
+	gnark_nimue.Arthur
+	challenge []uints.U8
+}
+
+func (a *challengeArthur) FillChallengeBytes(out []uints.U8) error {
+	if len(out) > len(a.challenge) {
+		return fmt.Errorf("%d challenge bytes squeezed, %d left", len(out), len(a.challenge))
+	}
+	copy(out, a.challenge)
+	a.challenge = a.challenge[len(out):]
+	return nil
+}
+
+type stirChallengesCircuit struct {
+	domainSize    int
+	foldingFactor int
+	Challenge     []uints.U8
+	Indexes       []frontend.Variable
+}
+
+func (c *stirChallengesCircuit) Define(api frontend.API) error {
+	circuit := Circuit{FoldingFactorArray: []int{c.foldingFactor}}
+	indexes, err := GetStirChallenges(api, circuit, &challengeArthur{challenge: c.Challenge}, len(c.Indexes), c.domainSize, 0)
+	if err != nil {
+		return err
+	}
+	for i := range indexes {
+		api.AssertIsEqual(indexes[i], c.Indexes[i])
+	}
+	return nil
+}
+
+func TestGetStirChallenges(t *testing.T) {
+	for _, tc := range []struct {
+		domainSize    int
+		foldingFactor int
+		challenge     []byte
+		indexes       []int
+	}{
+		// 2^8 folded positions take one byte each.
+		{1 << 9, 1, []byte{0xab, 0x07}, []int{0xab, 0x07}},
+		// 2^11 folded positions take two bytes, of which the top five bits
+		// are dropped.
+		{1 << 13, 2, []byte{0xab, 0xcd, 0xff, 0xff}, []int{0x3cd, 0x7ff}},
+		// 2^3 folded positions keep the bottom three bits of one byte.
+		{1 << 4, 1, []byte{0xab, 0x10, 0x07}, []int{3, 0, 7}},
+		// 2^16 folded positions take two full bytes, big-endian.
+		{1 << 20, 4, []byte{0x12, 0x34}, []int{0x1234}},
+	} {
+		circuit := &stirChallengesCircuit{
+			domainSize:    tc.domainSize,
+			foldingFactor: tc.foldingFactor,
+			Challenge:     make([]uints.U8, len(tc.challenge)),
+			Indexes:       make([]frontend.Variable, len(tc.indexes)),
+		}
+		assignment := func(indexes ...int) *stirChallengesCircuit {
+			res := &stirChallengesCircuit{Challenge: uints.NewU8Array(tc.challenge)}
+			for _, index := range indexes {
+				res.Indexes = append(res.Indexes, index)
+			}
+			return res
+		}
+		if err := test.IsSolved(circuit, assignment(tc.indexes...), ecc.BN254.ScalarField()); err != nil {
+			t.Errorf("challenge %x over %d positions: %v", tc.challenge, tc.domainSize>>tc.foldingFactor, err)
+		}
+		wrong := append([]int{tc.indexes[0] + 1}, tc.indexes[1:]...)
+		if err := test.IsSolved(circuit, assignment(wrong...), ecc.BN254.ScalarField()); err == nil {
+			t.Errorf("challenge %x over %d positions gives index %d", tc.challenge, tc.domainSize>>tc.foldingFactor, wrong[0])
+		}
+	}
+}
+
+// merkleCircuit opens the leaves of a Skyscraper tree of the given arity.
+type merkleCircuit struct {
+	arity             int
+	layout            []uint64
+	LeafIndexes       []uints.U64
+	Leaves            [][]frontend.Variable
+	LeafSiblingHashes [][]uints.U8
+	AuthPaths         [][][]uints.U8
+	Root              frontend.Variable
+}
+
+func (c *merkleCircuit) Define(api frontend.API) error {
+	uapi, err := uints.New[uints.U64](api)
+	if err != nil {
+		return err
+	}
+	hasher, err := newMerkleHasher(api, uapi, skyscraper.NewSkyscraper(api, 2), HashSkyscraper, LeafChain)
+	if err != nil {
+		return err
+	}
+	if c.layout != nil {
+		return VerifyMerkleMultiProof(api, uapi, hasher, c.arity, c.layout, c.LeafIndexes, c.Leaves, c.LeafSiblingHashes, c.AuthPaths, Digest{c.Root})
+	}
+	return VerifyMerkleTreeProofs(api, uapi, hasher, c.arity, c.LeafIndexes, c.Leaves, c.LeafSiblingHashes, c.AuthPaths, Digest{c.Root})
+}
+
+// openTree builds the tree of the given arity over the leaves and returns
+// the assignment of merkleCircuit opening it at the indexes.
+func openTree(t *testing.T, arity int, leaves [][]fr.Element, indexes []uint64) *merkleCircuit {
+	t.Helper()
+	hasher, err := newNativeMerkleHasher(HashSkyscraper, LeafChain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var level [][]byte
+	for _, leaf := range leaves {
+		digest, err := hasher.hashLeaf(leaf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		level = append(level, digest)
+	}
+	levels := [][][]byte{level}
+	for len(level) > 1 {
+		var next [][]byte
+		for i := 0; i < len(level); i += arity {
+			next = append(next, hasher.compress(level[i:i+arity]...))
+		}
+		levels = append(levels, next)
+		level = next
+	}
+
+	// siblings returns the siblings of the node at the given level, in the
+	// order of the children.
+	siblings := func(level int, index uint64) []uints.U8 {
+		var res []byte
+		first := index / uint64(arity) * uint64(arity)
+		for k := first; k < first+uint64(arity); k++ {
+			if k != index {
+				res = append(res, levels[level][k]...)
+			}
+		}
+		return uints.NewU8Array(res)
+	}
+	assignment := &merkleCircuit{Root: native.FromLittleEndian(levels[len(levels)-1][0])}
+	for _, index := range indexes {
+		leaf := make([]frontend.Variable, len(leaves[index]))
+		for i := range leaf {
+			leaf[i] = leaves[index][i]
+		}
+		var path [][]uints.U8
+		for level := 1; level < len(levels)-1; level++ {
+			path = append(path, siblings(level, index>>(level*bits.TrailingZeros(uint(arity)))))
+		}
+		assignment.LeafIndexes = append(assignment.LeafIndexes, uints.NewU64(index))
+		assignment.Leaves = append(assignment.Leaves, leaf)
+		assignment.LeafSiblingHashes = append(assignment.LeafSiblingHashes, siblings(0, index))
+		assignment.AuthPaths = append(assignment.AuthPaths, path)
+	}
+	return assignment
+}
+
+func TestVerifyMerkleTreeProofsArity4(t *testing.T) {
+	leaves := make([][]fr.Element, 16)
+	for i := range leaves {
+		leaves[i] = make([]fr.Element, 2)
+		leaves[i][0].SetUint64(uint64(2 * i))
+		leaves[i][1].SetUint64(uint64(2*i + 1))
+	}
+	indexes := []uint64{1, 6, 15}
+	assignment := openTree(t, 4, leaves, indexes)
+	circuit := &merkleCircuit{
+		arity:             4,
+		LeafIndexes:       make([]uints.U64, len(indexes)),
+		Leaves:            make([][]frontend.Variable, len(indexes)),
+		LeafSiblingHashes: make([][]uints.U8, len(indexes)),
+		AuthPaths:         make([][][]uints.U8, len(indexes)),
+	}
+	for i := range indexes {
+		circuit.Leaves[i] = make([]frontend.Variable, 2)
+		circuit.LeafSiblingHashes[i] = make([]uints.U8, 3*32)
+		circuit.AuthPaths[i] = [][]uints.U8{make([]uints.U8, 3*32)}
+	}
+
+	// The second leaf sits between its siblings on both levels.
+	swapped := *assignment
+	swapped.LeafSiblingHashes = append([][]uints.U8{}, assignment.LeafSiblingHashes...)
+	sibling := assignment.LeafSiblingHashes[1]
+	swapped.LeafSiblingHashes[1] = append(append(append([]uints.U8{}, sibling[32:64]...), sibling[:32]...), sibling[64:]...)
+
+	for _, layout := range [][]uint64{nil, indexes} {
+		circuit.layout, assignment.layout, swapped.layout = layout, layout, layout
+		if err := test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()); err != nil {
+			t.Errorf("layout %v: %v", layout, err)
+		}
+		if err := test.IsSolved(circuit, &swapped, ecc.BN254.ScalarField()); err == nil {
+			t.Errorf("layout %v: a path with swapped siblings is accepted", layout)
+		}
+	}
+}
+
+// foldCircuit folds the same cosets from their evaluations and from the
+// coefficients of their fold.
+type foldCircuit struct {
+	domainSize        int
+	LeafIndexes       []uints.U64
+	Evaluations       [][]frontend.Variable
+	Coefficients      [][]frontend.Variable
+	FoldingRandomness []frontend.Variable
+	DomainGenInv      frontend.Variable
+	Folds             []frontend.Variable
+}
+
+func (c *foldCircuit) Define(api frontend.API) error {
+	uapi, err := uints.New[uints.U64](api)
+	if err != nil {
+		return err
+	}
+	naive := computeFoldsNaive(api, uapi, c.Evaluations, c.LeafIndexes, c.FoldingRandomness, c.DomainGenInv, c.domainSize)
+	helped := computeFold(c.Coefficients, c.FoldingRandomness, api)
+	for j := range c.Folds {
+		api.AssertIsEqual(naive[j], c.Folds[j])
+		api.AssertIsEqual(helped[j], c.Folds[j])
+	}
+	return nil
+}
+
+func TestNaiveAndHelpedFoldsAgree(t *testing.T) {
+	const domainSize, foldingFactor = 32, 2
+	coefficients := make([]fr.Element, domainSize/2)
+	for i := range coefficients {
+		coefficients[i].SetUint64(uint64(3*i + 1))
+	}
+	generator := fft.NewDomain(domainSize).Generator
+	hasher, err := newNativeMerkleHasher(HashSkyscraper, LeafChain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	evaluations := commitTest(hasher, coefficients, domainSize, generator, foldingFactor, true)
+	folds := commitTest(hasher, coefficients, domainSize, generator, foldingFactor, false)
+
+	randomness := []fr.Element{fr.NewElement(5), fr.NewElement(11)}
+	var domainGenInv fr.Element
+	domainGenInv.Inverse(&generator)
+	cosetGenInv := native.Exponent(domainGenInv, domainSize>>foldingFactor)
+
+	indexes := []uint64{0, 3, 7}
+	circuit := &foldCircuit{
+		domainSize:        domainSize,
+		LeafIndexes:       make([]uints.U64, len(indexes)),
+		Evaluations:       make([][]frontend.Variable, len(indexes)),
+		Coefficients:      make([][]frontend.Variable, len(indexes)),
+		FoldingRandomness: make([]frontend.Variable, foldingFactor),
+		Folds:             make([]frontend.Variable, len(indexes)),
+	}
+	assignment := &foldCircuit{DomainGenInv: domainGenInv, FoldingRandomness: []frontend.Variable{randomness[0], randomness[1]}}
+	variables := func(leaf []fr.Element) []frontend.Variable {
+		res := make([]frontend.Variable, len(leaf))
+		for i := range leaf {
+			res[i] = leaf[i]
+		}
+		return res
+	}
+	for j, index := range indexes {
+		circuit.Evaluations[j] = make([]frontend.Variable, 1<<foldingFactor)
+		circuit.Coefficients[j] = make([]frontend.Variable, 1<<foldingFactor)
+
+		helped := native.MultivarPoly(folds.leaves[index], randomness)
+		naive := nativeComputeFoldNaive(evaluations.leaves[index], randomness, native.Exponent(domainGenInv, index), cosetGenInv)
+		if !naive.Equal(&helped) {
+			t.Errorf("coset %d folds to %s from its evaluations and to %s from its coefficients", index, naive.String(), helped.String())
+		}
+		assignment.LeafIndexes = append(assignment.LeafIndexes, uints.NewU64(index))
+		assignment.Evaluations = append(assignment.Evaluations, variables(evaluations.leaves[index]))
+		assignment.Coefficients = append(assignment.Coefficients, variables(folds.leaves[index]))
+		assignment.Folds = append(assignment.Folds, helped)
+	}
+	if err := test.IsSolved(circuit, assignment, ecc.BN254.ScalarField()); err != nil {
+		t.Error(err)
+	}
+
+	// The evaluations of another coset fold to another value.
+	swapped := *assignment
+	swapped.Evaluations = append([][]frontend.Variable{}, assignment.Evaluations...)
+	swapped.Evaluations[1] = variables(evaluations.leaves[indexes[1]+1])
+	if err := test.IsSolved(circuit, &swapped, ecc.BN254.ScalarField()); err == nil {
+		t.Error("the evaluations of another coset are accepted")
+	}
+}
+
+// A proof whose leaves hold the evaluations over the cosets verifies with
+// the naive folds only, and one holding the coefficients of the folds
+// without them.
+func TestNaiveFoldsProof(t *testing.T) {
+	for _, naiveFolds := range []bool{false, true} {
+		proof, cfg, r1cs, interner := proveTestProgram(t, squareProgram, naiveFolds)
+		for _, verifyNaive := range []bool{false, true} {
+			var opts []Option
+			if verifyNaive {
+				opts = append(opts, WithNaiveFolds())
+			}
+			v, err := NewVerifier(proof, cfg, r1cs, interner, opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkErr, solveErr := v.Check(), isSolved(v)
+			if naiveFolds == verifyNaive && (checkErr != nil || solveErr != nil) {
+				t.Errorf("naive folds %v: %v, %v", naiveFolds, checkErr, solveErr)
+			}
+			if naiveFolds != verifyNaive && (checkErr == nil || solveErr == nil) {
+				t.Errorf("a proof with naive folds %v verifies with naive folds %v", naiveFolds, verifyNaive)
+			}
+		}
+	}
+}
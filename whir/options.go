@@ -0,0 +1,88 @@
+package whir
+
+import (
+	"math/big"
+
+	"reilabs/whir-verifier-circuit/utilities"
+)
+
+// Option configures the circuit built by NewVerifier. Options change the
+// circuit shape, so they are part of the cache key.
+type Option func(*settings)
+
+type settings struct {
+	Backend        Backend
+	TranscriptMode TranscriptMode
+	Universal      *UniversalBounds  `json:",omitempty"`
+	CommitMatrices bool              `json:",omitempty"`
+	NaiveFolds     bool              `json:",omitempty"`
+	PoWMode        utilities.PoWMode `json:",omitempty"`
+	MerkleHash     Hash              `json:",omitempty"`
+	SharedNodes    bool              `json:",omitempty"`
+	// PublicInputs are values, not shape, so they stay out of the cache key.
+	PublicInputs []*big.Int `json:"-"`
+}
+
+func newSettings(opts []Option) settings {
+	s := settings{
+		Backend:        BackendGroth16,
+		TranscriptMode: TranscriptPublic,
+	}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	return s
+}
+
+// WithTranscriptMode selects whether the transcript is a public input or is
+// replaced by a public digest, see TranscriptMode.
+func WithTranscriptMode(mode TranscriptMode) Option {
+	return func(s *settings) {
+		s.TranscriptMode = mode
+	}
+}
+
+// WithBackend selects the proof system, Groth16 by default.
+func WithBackend(backend Backend) Option {
+	return func(s *settings) {
+		s.Backend = backend
+	}
+}
+
+// WithNaiveFolds verifies proofs whose leaves hold the evaluations of every
+// coset instead of the coefficients of its fold, as produced by WHIR's
+// FoldType::Naive.
+func WithNaiveFolds() Option {
+	return func(s *settings) {
+		s.NaiveFolds = true
+	}
+}
+
+// WithSharedNodes hashes every node the Merkle paths go through once instead
+// of once per path. The layout of the nodes is taken from the leaf indexes of
+// the proof, so the circuit only verifies proofs opening the same leaves. The
+// leaf indexes are random per proof, so in practice the setup, and its cache
+// entry, verify the one proof they were built from and cannot be reused.
+func WithSharedNodes() Option {
+	return func(s *settings) {
+		s.SharedNodes = true
+	}
+}
+
+// WithPoWMode selects how the field proof of work hashes are checked against
+// the difficulty, utilities.PoWThreshold by default. A Keccak pow_hash always
+// follows the nimue-pow rule of utilities.KeccakPoW.
+func WithPoWMode(mode utilities.PoWMode) Option {
+	return func(s *settings) {
+		s.PoWMode = mode
+	}
+}
+
+// WithPublicInputs gives the values of the R1CS public inputs, the witness
+// entries following the constant one, which become public inputs of the
+// wrapper proof. The proof file does not carry them, see LoadPublicInputs.
+func WithPublicInputs(values []*big.Int) Option {
+	return func(s *settings) {
+		s.PublicInputs = values
+	}
+}
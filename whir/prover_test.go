@@ -0,0 +1,592 @@
+package whir
+
+import (
+	"fmt"
+	"math/bits"
+	"reilabs/whir-verifier-circuit/native"
+	"slices"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr/fft"
+	"github.com/consensys/gnark/test"
+)
+
+// The tests need WHIR proofs of R1CS programs, so this file holds a small
+// WHIR prover. It commits to the witness, runs the R1CS sumcheck and the WHIR
+// rounds the way Circuit.Define checks them, with the proof of work disabled
+// and a constant folding factor, and writes the proof, params and R1CS in
+// the ProveKit formats.
+
+// testOp is a transcript operation of testMerlin.
+type testOp struct {
+	squeeze bool
+	bytes   bool
+	n       int
+}
+
+// testMerlin writes the prover side of a transcript over a field sponge.
+// Challenges are drawn by replaying the transcript written so far with the
+// native Arthur, so that they match the verifier. Without an IO pattern it
+// only records the operations, drawing zero challenges, which gives the IO
+// pattern of the proof.
+type testMerlin struct {
+	io         []byte
+	newArthur  func(io []byte, transcript []byte) (native.Arthur, error)
+	transcript []byte
+	ops        []testOp
+}
+
+func (m *testMerlin) addScalars(xs ...fr.Element) {
+	for _, x := range xs {
+		le := native.LittleEndianBytes(x)
+		m.transcript = append(m.transcript, le[:]...)
+	}
+	m.ops = append(m.ops, testOp{n: len(xs)})
+}
+
+func (m *testMerlin) challengeScalars(n int) []fr.Element {
+	res := make([]fr.Element, n)
+	m.squeeze(testOp{squeeze: true, n: n}, func(arthur native.Arthur) error {
+		return arthur.FillChallengeScalars(res)
+	})
+	return res
+}
+
+func (m *testMerlin) challengeBytes(n int) []byte {
+	res := make([]byte, n)
+	if n == 0 {
+		return res
+	}
+	m.squeeze(testOp{squeeze: true, bytes: true, n: n}, func(arthur native.Arthur) error {
+		return arthur.FillChallengeBytes(res)
+	})
+	return res
+}
+
+func (m *testMerlin) squeeze(op testOp, fill func(native.Arthur) error) {
+	defer func() { m.ops = append(m.ops, op) }()
+	if m.io == nil {
+		return
+	}
+	arthur, err := m.newArthur(m.io, m.transcript)
+	if err != nil {
+		panic(err)
+	}
+	for _, op := range m.ops {
+		switch {
+		case !op.squeeze:
+			err = arthur.FillNextScalars(make([]fr.Element, op.n))
+		case op.bytes:
+			err = arthur.FillChallengeBytes(make([]byte, op.n))
+		default:
+			err = arthur.FillChallengeScalars(make([]fr.Element, op.n))
+		}
+		if err != nil {
+			panic(err)
+		}
+	}
+	if err := fill(arthur); err != nil {
+		panic(err)
+	}
+}
+
+// ioPattern returns the IO pattern of the recorded operations, counted in
+// field elements as the field sponges of gnark-nimue do.
+func (m *testMerlin) ioPattern() []byte {
+	io := []byte("whir-test\x00")
+	for i := 0; i < len(m.ops); {
+		squeeze, n := m.ops[i].squeeze, 0
+		for ; i < len(m.ops) && m.ops[i].squeeze == squeeze; i++ {
+			op := m.ops[i]
+			if op.bytes {
+				perScalar := min(op.n, 15)
+				n += (op.n + perScalar - 1) / perScalar
+			} else {
+				n += op.n
+			}
+		}
+		kind := 'A'
+		if squeeze {
+			kind = 'S'
+		}
+		io = fmt.Appendf(io, "%c%d\x00", kind, n)
+	}
+	return io
+}
+
+// testProgram is an R1CS program together with a satisfying witness, whose
+// length is a power of two.
+type testProgram struct {
+	logConstraints int
+	// a, b and c list the entries of the matrices as row, column, value.
+	a, b, c [][3]int64
+	witness []int64
+}
+
+func (p testProgram) logVars() int {
+	return bits.Len(uint(len(p.witness))) - 1
+}
+
+// r1cs returns the program in the ProveKit format.
+func (p testProgram) r1cs() (R1CS, Interner) {
+	var interner Interner
+	interned := map[int64]uint64{}
+	intern := func(value int64) uint64 {
+		if i, ok := interned[value]; ok {
+			return i
+		}
+		var e fr.Element
+		e.SetInt64(value)
+		interned[value] = uint64(len(interner.Values))
+		interner.Values = append(interner.Values, Fp256{Limbs: e.Bits()})
+		return interned[value]
+	}
+	sparse := func(entries [][3]int64) SparseMatrix {
+		entries = slices.Clone(entries)
+		slices.SortFunc(entries, func(x, y [3]int64) int {
+			if x[0] != y[0] {
+				return int(x[0] - y[0])
+			}
+			return int(x[1] - y[1])
+		})
+		m := SparseMatrix{Rows: 1 << p.logConstraints, Cols: uint64(len(p.witness))}
+		for row := range int64(1 << p.logConstraints) {
+			m.RowIndices = append(m.RowIndices, uint64(len(m.ColIndices)))
+			for _, entry := range entries {
+				if entry[0] == row {
+					m.ColIndices = append(m.ColIndices, uint64(entry[1]))
+					m.Values = append(m.Values, intern(entry[2]))
+				}
+			}
+		}
+		return m
+	}
+	r1cs := R1CS{
+		Witnesses:   uint64(len(p.witness)),
+		Constraints: 1 << p.logConstraints,
+		A:           sparse(p.a),
+		B:           sparse(p.b),
+		C:           sparse(p.c),
+	}
+	return r1cs, interner
+}
+
+// products returns A·z, B·z and C·z.
+func (p testProgram) products() [3][]fr.Element {
+	var products [3][]fr.Element
+	for m, entries := range [][][3]int64{p.a, p.b, p.c} {
+		products[m] = make([]fr.Element, 1<<p.logConstraints)
+		for _, entry := range entries {
+			var value, term fr.Element
+			value.SetInt64(entry[2])
+			term.SetInt64(p.witness[entry[1]])
+			term.Mul(&term, &value)
+			products[m][entry[0]].Add(&products[m][entry[0]], &term)
+		}
+	}
+	return products
+}
+
+// testParams returns the params of a proof of a program with 2^logVars
+// witness entries, folding one variable per round. Their IO pattern,
+// transcript and statement evaluations are filled in by proveTestWHIR.
+func testParams(logConstraints int, logVars int) Config {
+	nRounds := logVars - 1
+	cfg := Config{
+		LogNumConstraints: logConstraints,
+		NRounds:           nRounds,
+		NVars:             logVars,
+		FoldingFactor:     slices.Repeat([]int{1}, nRounds+1),
+		OODSamples:        slices.Repeat([]int{1}, nRounds),
+		NumQueries:        slices.Repeat([]int{2}, nRounds),
+		PowBits:           make([]int, nRounds),
+		FinalQueries:      2,
+		Rate:              1,
+	}
+	domain := fft.NewDomain(uint64(startingDomainSize(cfg)))
+	cfg.DomainGenerator = domain.Generator.String()
+	return cfg
+}
+
+// proveTestWHIR proves the program for the params, committing to the folds of
+// the cosets as coefficients, or as evaluations with naiveFolds.
+func proveTestWHIR(t *testing.T, p testProgram, cfg Config, naiveFolds bool) (ProofObject, Config, R1CS, Interner) {
+	t.Helper()
+	recorder := &testMerlin{}
+	if _, _, err := p.prove(recorder, cfg, naiveFolds); err != nil {
+		t.Fatal(err)
+	}
+	merlin := &testMerlin{io: recorder.ioPattern(), newArthur: native.NewSkyscraperArthur}
+	switch Hash(cfg.TranscriptHash) {
+	case HashPoseidon2:
+		merlin.newArthur = native.NewPoseidon2Arthur
+	case HashMiMC:
+		merlin.newArthur = native.NewMiMCArthur
+	}
+	proof, statements, err := p.prove(merlin, cfg, naiveFolds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.IOPattern = string(merlin.io)
+	cfg.Transcript = merlin.transcript
+	cfg.TranscriptLen = len(merlin.transcript)
+	for _, statement := range statements {
+		cfg.StatementEvaluations = append(cfg.StatementEvaluations, statement.String())
+	}
+	r1cs, interner := p.r1cs()
+	return proof, cfg, r1cs, interner
+}
+
+// testTree is a binary Merkle tree over the folds of the cosets of a domain.
+type testTree struct {
+	leaves [][]fr.Element
+	nodes  [][][]byte
+}
+
+func (p testProgram) prove(m *testMerlin, cfg Config, naiveFolds bool) (ProofObject, []fr.Element, error) {
+	hasher, err := newNativeMerkleHasher(HashSkyscraper, LeafChain)
+	if err != nil {
+		return ProofObject{}, nil, err
+	}
+	foldingFactor := cfg.FoldingFactor[0]
+	if cfg.NVars%foldingFactor != 0 || cfg.NVars/foldingFactor != cfg.NRounds+1 {
+		return ProofObject{}, nil, fmt.Errorf("%d variables do not fold in %d rounds of %d", cfg.NVars, cfg.NRounds+1, foldingFactor)
+	}
+
+	// The R1CS sumcheck reduces A·z ∘ B·z = C·z to the evaluations of the
+	// products at sp_rand, binding the most significant variable first.
+	tRand := m.challengeScalars(cfg.LogNumConstraints)
+	products := p.products()
+	tables := [][]fr.Element{native.EqOverBooleanHypercube(tRand), products[0], products[1], products[2]}
+	var spRand []fr.Element
+	for range cfg.LogNumConstraints {
+		half := len(tables[0]) / 2
+		evaluations := make([]fr.Element, 4)
+		for x := range evaluations {
+			var point fr.Element
+			point.SetUint64(uint64(x))
+			for j := range half {
+				v := make([]fr.Element, 4)
+				for i, table := range tables {
+					v[i] = lerp(table[j], table[j+half], point)
+				}
+				var term fr.Element
+				term.Mul(&v[1], &v[2]).Sub(&term, &v[3]).Mul(&term, &v[0])
+				evaluations[x].Add(&evaluations[x], &term)
+			}
+		}
+		m.addScalars(interpolate(evaluations)...)
+		r := m.challengeScalars(1)[0]
+		for i, table := range tables {
+			for j := range half {
+				table[j] = lerp(table[j], table[j+half], r)
+			}
+			tables[i] = table[:half]
+		}
+		spRand = append(spRand, r)
+	}
+	statements := []fr.Element{tables[1][0], tables[2][0], tables[3][0]}
+
+	// The multilinear witness polynomial in coefficients, such that its
+	// evaluations over the hypercube, indexed most significant variable
+	// first, are the witness.
+	coefficients := make([]fr.Element, len(p.witness))
+	for i, x := range p.witness {
+		coefficients[i].SetInt64(x)
+	}
+	for bit := 1; bit < len(coefficients); bit <<= 1 {
+		for i := range coefficients {
+			if i&bit != 0 {
+				coefficients[i].Sub(&coefficients[i], &coefficients[i^bit])
+			}
+		}
+	}
+	evaluations := make([]fr.Element, len(p.witness))
+	for i, x := range p.witness {
+		evaluations[i].SetInt64(x)
+	}
+
+	var generator fr.Element
+	if _, err := generator.SetString(cfg.DomainGenerator); err != nil {
+		return ProofObject{}, nil, err
+	}
+	domainSize := startingDomainSize(cfg)
+	tree := commitTest(hasher, coefficients, domainSize, generator, foldingFactor, naiveFolds)
+	m.addScalars(tree.root())
+	oodPoint := m.challengeScalars(1)[0]
+	m.addScalars(native.UnivarPoly(coefficients, oodPoint))
+	m.challengeScalars(1)
+
+	// The weights of the claims, over the hypercube like the evaluations.
+	combination := native.ExpandRandomness(m.challengeScalars(1)[0], 1+len(statements))
+	weights := make([]fr.Element, len(p.witness))
+	addEqWeight(weights, oodPoint, combination[0])
+	rowEq := native.EqOverBooleanHypercube(spRand)
+	for s, entries := range [][][3]int64{p.a, p.b, p.c} {
+		for _, entry := range entries {
+			var w fr.Element
+			w.SetInt64(entry[2])
+			w.Mul(&w, &rowEq[entry[0]]).Mul(&w, &combination[1+s])
+			weights[entry[1]].Add(&weights[entry[1]], &w)
+		}
+	}
+	var foldingRandomness []fr.Element
+	foldingRandomness, coefficients, evaluations, weights = sumcheckTest(m, foldingFactor, coefficients, evaluations, weights)
+
+	var proof ProofObject
+	for r := range cfg.NRounds {
+		var nextGenerator fr.Element
+		nextGenerator.Square(&generator)
+		next := commitTest(hasher, coefficients, domainSize/2, nextGenerator, foldingFactor, naiveFolds)
+		m.addScalars(next.root())
+		oodPoints := m.challengeScalars(cfg.OODSamples[r])
+		for _, point := range oodPoints {
+			m.addScalars(native.UnivarPoly(coefficients, point))
+		}
+		indexes := stirIndexesTest(m, cfg.NumQueries[r], domainSize, foldingFactor)
+		opening := tree.open(hasher, indexes)
+		if r == 0 {
+			proof.FirstRoundPaths = append(proof.FirstRoundPaths, opening)
+		} else {
+			proof.MerklePaths = append(proof.MerklePaths, opening)
+		}
+
+		points := oodPoints
+		foldedGenerator := native.Exponent(generator, uint64(1)<<foldingFactor)
+		for _, index := range opening.A.LeafIndexes {
+			points = append(points, native.Exponent(foldedGenerator, index))
+		}
+		combination := native.ExpandRandomness(m.challengeScalars(1)[0], len(points))
+		for i, point := range points {
+			addEqWeight(weights, point, combination[i])
+		}
+		var roundRandomness []fr.Element
+		roundRandomness, coefficients, evaluations, weights = sumcheckTest(m, foldingFactor, coefficients, evaluations, weights)
+		foldingRandomness = append(foldingRandomness, roundRandomness...)
+		tree, domainSize, generator = next, domainSize/2, nextGenerator
+	}
+
+	m.addScalars(coefficients...)
+	indexes := stirIndexesTest(m, cfg.FinalQueries, domainSize, foldingFactor)
+	proof.MerklePaths = append(proof.MerklePaths, tree.open(hasher, indexes))
+	proof.StatementValuesAtRandomPoint = make([]Fp256, len(statements))
+	return proof, statements, nil
+}
+
+// commitTest commits to the polynomial with the given coefficients over the
+// domain of the given size and generator, one leaf for every coset of the
+// subgroup of order 2^foldingFactor.
+func commitTest(hasher nativeMerkleHasher, coefficients []fr.Element, domainSize int, generator fr.Element, foldingFactor int, naiveFolds bool) *testTree {
+	cosets := domainSize >> foldingFactor
+	foldedGenerator := native.Exponent(generator, uint64(1)<<foldingFactor)
+	cosetGenerator := native.Exponent(generator, uint64(cosets))
+	tree := &testTree{leaves: make([][]fr.Element, cosets)}
+	for index := range cosets {
+		leaf := make([]fr.Element, 1<<foldingFactor)
+		offset := native.Exponent(generator, uint64(index))
+		point := native.Exponent(foldedGenerator, uint64(index))
+		for b := range leaf {
+			if naiveFolds {
+				// The evaluations over the coset.
+				leaf[b] = native.UnivarPoly(coefficients, offset)
+				offset.Mul(&offset, &cosetGenerator)
+			} else {
+				// The coefficients of the fold, the polynomials of the
+				// coefficients congruent to b evaluated at the folded point.
+				var part []fr.Element
+				for i := b; i < len(coefficients); i += len(leaf) {
+					part = append(part, coefficients[i])
+				}
+				leaf[b] = native.UnivarPoly(part, point)
+			}
+		}
+		tree.leaves[index] = leaf
+	}
+
+	level := make([][]byte, cosets)
+	for i, leaf := range tree.leaves {
+		level[i], _ = hasher.hashLeaf(leaf)
+	}
+	tree.nodes = append(tree.nodes, level)
+	for len(level) > 1 {
+		next := make([][]byte, len(level)/2)
+		for i := range next {
+			next[i] = hasher.compress(level[2*i], level[2*i+1])
+		}
+		tree.nodes = append(tree.nodes, next)
+		level = next
+	}
+	return tree
+}
+
+func (tree *testTree) root() fr.Element {
+	return native.FromLittleEndian(tree.nodes[len(tree.nodes)-1][0])
+}
+
+// open returns the paths of the distinct queried leaves, in increasing order.
+func (tree *testTree) open(hasher nativeMerkleHasher, indexes []uint64) ProofElement {
+	indexes = slices.Clone(indexes)
+	slices.Sort(indexes)
+	indexes = slices.Compact(indexes)
+	var element ProofElement
+	digest := func(node []byte) KeccakDigest {
+		var d KeccakDigest
+		copy(d.KeccakDigest[:], node)
+		return d
+	}
+	for _, index := range indexes {
+		element.A.LeafIndexes = append(element.A.LeafIndexes, index)
+		element.A.LeafSiblingHashes = append(element.A.LeafSiblingHashes, digest(tree.nodes[0][index^1]))
+		// The suffixes list the siblings from the root down.
+		var path []KeccakDigest
+		for level := len(tree.nodes) - 2; level > 0; level-- {
+			path = append(path, digest(tree.nodes[level][index>>level^1]))
+		}
+		element.A.AuthPathsSuffixes = append(element.A.AuthPathsSuffixes, path)
+		element.A.AuthPathsPrefixLengths = append(element.A.AuthPathsPrefixLengths, 0)
+		leaf := make([]Fp256, len(tree.leaves[index]))
+		for i, x := range tree.leaves[index] {
+			leaf[i] = Fp256{Limbs: x.Bits()}
+		}
+		element.B = append(element.B, leaf)
+	}
+	return element
+}
+
+// stirIndexesTest mirrors GetStirChallenges.
+func stirIndexesTest(m *testMerlin, numQueries int, domainSize int, foldingFactor int) []uint64 {
+	foldedDomainSize := domainSize >> foldingFactor
+	domainSizeBytes := (bits.Len(uint(foldedDomainSize*2-1)) - 1 + 7) / 8
+	queries := m.challengeBytes(domainSizeBytes * numQueries)
+	mask := uint64(foldedDomainSize - 1)
+	indexes := make([]uint64, numQueries)
+	for i := range indexes {
+		for _, b := range queries[i*domainSizeBytes : (i+1)*domainSizeBytes] {
+			indexes[i] = indexes[i]<<8 | uint64(b)
+		}
+		indexes[i] &= mask
+	}
+	return indexes
+}
+
+// sumcheckTest runs rounds of the WHIR sumcheck of the polynomial against
+// the weights, binding the least significant variable first, and returns the
+// randomness with the folded polynomial and weights.
+func sumcheckTest(m *testMerlin, rounds int, coefficients, evaluations, weights []fr.Element) ([]fr.Element, []fr.Element, []fr.Element, []fr.Element) {
+	randomness := make([]fr.Element, rounds)
+	for i := range rounds {
+		polynomial := make([]fr.Element, 3)
+		for x := range polynomial {
+			var point fr.Element
+			point.SetUint64(uint64(x))
+			for j := range len(evaluations) / 2 {
+				f := lerp(evaluations[2*j], evaluations[2*j+1], point)
+				w := lerp(weights[2*j], weights[2*j+1], point)
+				f.Mul(&f, &w)
+				polynomial[x].Add(&polynomial[x], &f)
+			}
+		}
+		m.addScalars(polynomial...)
+		randomness[i] = m.challengeScalars(1)[0]
+		coefficients = foldPairs(coefficients, randomness[i], false)
+		evaluations = foldPairs(evaluations, randomness[i], true)
+		weights = foldPairs(weights, randomness[i], true)
+	}
+	return randomness, coefficients, evaluations, weights
+}
+
+// foldPairs binds the least significant variable of a polynomial to r, given
+// in coefficients or, with evaluations, over the hypercube.
+func foldPairs(v []fr.Element, r fr.Element, evaluations bool) []fr.Element {
+	res := make([]fr.Element, len(v)/2)
+	for j := range res {
+		if evaluations {
+			res[j] = lerp(v[2*j], v[2*j+1], r)
+		} else {
+			res[j].Mul(&v[2*j+1], &r).Add(&res[j], &v[2*j])
+		}
+	}
+	return res
+}
+
+// addEqWeight adds scale·eq(point, x) over the hypercube x, with point
+// expanded from the univariate one.
+func addEqWeight(weights []fr.Element, point fr.Element, scale fr.Element) {
+	eq := native.EqOverBooleanHypercube(native.ExpandFromUnivariate(point, bits.Len(uint(len(weights)))-1))
+	for i := range weights {
+		var w fr.Element
+		w.Mul(&eq[i], &scale)
+		weights[i].Add(&weights[i], &w)
+	}
+}
+
+// lerp evaluates the line through (0, a) and (1, b) at x.
+func lerp(a, b, x fr.Element) fr.Element {
+	var res fr.Element
+	res.Sub(&b, &a).Mul(&res, &x).Add(&res, &a)
+	return res
+}
+
+// interpolate returns the coefficients of the polynomial with the given
+// evaluations at 0, 1, ..., len(evaluations)-1.
+func interpolate(evaluations []fr.Element) []fr.Element {
+	res := make([]fr.Element, len(evaluations))
+	for i := range evaluations {
+		basis := []fr.Element{evaluations[i]}
+		for j := range evaluations {
+			if j == i {
+				continue
+			}
+			var scale, node fr.Element
+			scale.SetInt64(int64(i - j))
+			scale.Inverse(&scale)
+			node.SetInt64(int64(j))
+			next := make([]fr.Element, len(basis)+1)
+			for k := range basis {
+				var term fr.Element
+				term.Mul(&basis[k], &scale)
+				next[k+1].Add(&next[k+1], &term)
+				term.Mul(&term, &node)
+				next[k].Sub(&next[k], &term)
+			}
+			basis = next
+		}
+		for k := range basis {
+			res[k].Add(&res[k], &basis[k])
+		}
+	}
+	return res
+}
+
+// The test programs. squareProgram checks 3·5 = 15 with two constraints over
+// four witness entries, and chainProgram 3·5·2 = 30 with three out of four
+// constraints over eight entries, so their proofs differ in every size.
+var (
+	squareProgram = testProgram{
+		logConstraints: 1,
+		a:              [][3]int64{{0, 1, 1}, {1, 0, 1}},
+		b:              [][3]int64{{0, 2, 1}, {1, 1, 1}},
+		c:              [][3]int64{{0, 3, 1}, {1, 1, 1}},
+		witness:        []int64{1, 3, 5, 15},
+	}
+	chainProgram = testProgram{
+		logConstraints: 2,
+		a:              [][3]int64{{0, 1, 1}, {1, 3, 1}, {2, 0, 1}},
+		b:              [][3]int64{{0, 2, 1}, {1, 4, 1}, {2, 0, 1}},
+		c:              [][3]int64{{0, 3, 1}, {1, 5, 1}, {2, 0, 1}},
+		witness:        []int64{1, 3, 5, 15, 2, 30, 0, 0},
+	}
+)
+
+// proveTestProgram proves the program with the params of its size.
+func proveTestProgram(t *testing.T, p testProgram, naiveFolds bool) (ProofObject, Config, R1CS, Interner) {
+	t.Helper()
+	return proveTestWHIR(t, p, testParams(p.logConstraints, p.logVars()), naiveFolds)
+}
+
+// isSolved runs the circuit of v on its witness with the test engine.
+func isSolved(v *Verifier) error {
+	return test.IsSolved(&v.circuit, &v.assignment, ecc.BN254.ScalarField(), test.WithBackendProverOptions(proverOptions(BackendGroth16)...))
+}
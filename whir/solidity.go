@@ -0,0 +1,258 @@
+package whir
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+	"reilabs/whir-verifier-circuit/native"
+	"strings"
+	"text/template"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	curve "github.com/consensys/gnark-crypto/ecc/bn254"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fp"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/backend"
+	"github.com/consensys/gnark/backend/groth16"
+	groth16_bn254 "github.com/consensys/gnark/backend/groth16/bn254"
+	"github.com/consensys/gnark/backend/plonk"
+	plonk_bn254 "github.com/consensys/gnark/backend/plonk/bn254"
+	"github.com/consensys/gnark/backend/solidity"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/frontend"
+	"golang.org/x/crypto/sha3"
+)
+
+const wordSize = 32
+
+// Calldata holds the arguments of verifyProof in the Solidity verifier
+// written by ExportSolidity, as 0x-prefixed 32 byte words, together with the
+// ABI encoded call.
+type Calldata struct {
+	Proof         []string `json:"proof"`
+	Commitments   []string `json:"commitments,omitempty"`
+	CommitmentPok []string `json:"commitment_pok,omitempty"`
+	Input         []string `json:"input"`
+	Calldata      string   `json:"calldata"`
+}
+
+// ExportSolidity writes the gnark Solidity verifier for the verifying key.
+// The Groth16 verifier hashes commitments with Keccak-256 and the PLONK one
+// with the default hash-to-field of gnark, as Prove does.
+func (v *Verifier) ExportSolidity(w io.Writer) error {
+	if vk, ok := v.vk.(plonk.VerifyingKey); ok && v.Backend() == BackendPlonk {
+		return WithStage(StageExport, vk.ExportSolidity(w))
+	}
+	vk, ok := v.vk.(*groth16_bn254.VerifyingKey)
+	if !ok {
+		return WithStage(StageExport, fmt.Errorf("verifying key is not set up or has unsupported type %T", v.vk))
+	}
+	return WithStage(StageExport, exportSolidity(w, *vk))
+}
+
+// exportSolidity renders solidityTemplate the way gnark's
+// VerifyingKey.ExportSolidity does.
+func exportSolidity(w io.Writer, vk groth16_bn254.VerifyingKey) error {
+	if len(vk.PublicAndCommitmentCommitted) > 1 {
+		return fmt.Errorf("the Solidity verifier supports at most one commitment, the circuit has %d", len(vk.PublicAndCommitmentCommitted))
+	}
+	cfg, err := solidity.NewExportConfig()
+	if err != nil {
+		return err
+	}
+	helpers := template.FuncMap{
+		"sum": func(a, b int) int { return a + b },
+		"sub": func(a, b int) int { return a - b },
+		"mul": func(a, b int) int { return a * b },
+		"intRange": func(max int) []int {
+			out := make([]int, max)
+			for i := range out {
+				out[i] = i
+			}
+			return out
+		},
+		"fpstr": func(x fp.Element) string {
+			return x.BigInt(new(big.Int)).String()
+		},
+		"hashFnName": func() string { return "keccak256" },
+	}
+	tmpl, err := template.New("").Funcs(helpers).Parse(solidityTemplate)
+	if err != nil {
+		return err
+	}
+
+	// Beta, Gamma and Delta are negated so that the verifier does not have to
+	// negate the proof elements.
+	vk.G2.Beta.Neg(&vk.G2.Beta)
+	vk.G2.Gamma.Neg(&vk.G2.Gamma)
+	vk.G2.Delta.Neg(&vk.G2.Delta)
+	return tmpl.Execute(w, struct {
+		Cfg solidity.ExportConfig
+		Vk  groth16_bn254.VerifyingKey
+	}{cfg, vk})
+}
+
+// Calldata formats proof and the public inputs of the WHIR proof the verifier
+// was built for as a call to the verifier exported by ExportSolidity:
+// verifyProof for Groth16 and Verify for PLONK.
+func (v *Verifier) Calldata(proof Proof) (*Calldata, error) {
+	return formatCalldata(proof, &v.assignment)
+}
+
+func formatCalldata(proof Proof, assignment frontend.Circuit) (*Calldata, error) {
+	publicWitness, err := frontend.NewWitness(assignment, ecc.BN254.ScalarField(), frontend.PublicOnly())
+	if err != nil {
+		return nil, WithStage(StageWitness, err)
+	}
+	inputs, ok := publicWitness.Vector().(fr.Vector)
+	if !ok {
+		return nil, WithStage(StageExport, fmt.Errorf("unsupported witness type %T", publicWitness.Vector()))
+	}
+	var inputWords []byte
+	for i := range inputs {
+		word := inputs[i].Bytes()
+		inputWords = append(inputWords, word[:]...)
+	}
+
+	switch p := proof.(type) {
+	case *groth16_bn254.Proof:
+		return groth16Calldata(p, inputWords), nil
+	case *plonk_bn254.Proof:
+		return plonkCalldata(p, inputWords), nil
+	}
+	return nil, WithStage(StageExport, fmt.Errorf("unsupported proof type %T", proof))
+}
+
+func groth16Calldata(p *groth16_bn254.Proof, inputWords []byte) *Calldata {
+	// MarshalSolidity writes Ar, Bs and Krs, followed by the number of
+	// commitments as a uint32, the commitments and their proof of knowledge.
+	raw := p.MarshalSolidity()
+	words := append([]byte{}, raw[:8*wordSize]...)
+	c := &Calldata{Proof: hexWords(raw[:8*wordSize]), Input: hexWords(inputWords)}
+	if len(p.Commitments) > 0 {
+		commitments := raw[8*wordSize+4:]
+		words = append(words, commitments...)
+		c.Commitments = hexWords(commitments[:len(commitments)-2*wordSize])
+		c.CommitmentPok = hexWords(commitments[len(commitments)-2*wordSize:])
+	}
+	words = append(words, inputWords...)
+
+	encoded := append(verifyProofSelector(len(c.Commitments), len(c.Input)), words...)
+	c.Calldata = "0x" + hex.EncodeToString(encoded)
+	return c
+}
+
+// plonkCalldata encodes Verify(bytes proof, uint256[] public_inputs), whose
+// arguments are both dynamic: the head holds their offsets and the tail their
+// lengths followed by their words.
+func plonkCalldata(p *plonk_bn254.Proof, inputWords []byte) *Calldata {
+	raw := p.MarshalSolidity()
+	c := &Calldata{Proof: hexWords(raw), Input: hexWords(inputWords)}
+
+	hash := native.Keccak256([]byte("Verify(bytes,uint256[])"))
+	encoded := append([]byte{}, hash[:4]...)
+	encoded = append(encoded, abiWord(2*wordSize)...)
+	encoded = append(encoded, abiWord(3*wordSize+len(raw))...)
+	encoded = append(encoded, abiWord(len(raw))...)
+	encoded = append(encoded, raw...)
+	encoded = append(encoded, abiWord(len(c.Input))...)
+	encoded = append(encoded, inputWords...)
+	c.Calldata = "0x" + hex.EncodeToString(encoded)
+	return c
+}
+
+func abiWord(x int) []byte {
+	word := make([]byte, wordSize)
+	big.NewInt(int64(x)).FillBytes(word)
+	return word
+}
+
+// CheckCalldata decodes the ABI encoded call in c back into a Groth16 proof
+// and public witness and verifies them against the verifying key, without an
+// EVM. It accepts exactly the calls the exported Solidity verifier accepts.
+// Only Groth16 calls are supported, as the PLONK calldata leaves out the
+// opening of the linearised polynomial, which the contract recomputes.
+func (v *Verifier) CheckCalldata(c *Calldata) error {
+	vk, ok := v.vk.(*groth16_bn254.VerifyingKey)
+	if !ok {
+		return WithStage(StageExport, fmt.Errorf("calldata is only checked for Groth16 verifying keys, got %T", v.vk))
+	}
+	encoded, err := hex.DecodeString(strings.TrimPrefix(c.Calldata, "0x"))
+	if err != nil {
+		return WithStage(StageExport, fmt.Errorf("invalid calldata: %w", err))
+	}
+
+	nbCommitments := len(vk.PublicAndCommitmentCommitted)
+	nbInputs := (len(encoded) - 4) / wordSize
+	if nbCommitments > 0 {
+		nbInputs -= 2*nbCommitments + 2
+	}
+	nbInputs -= 8
+	if nbInputs < 0 || (len(encoded)-4)%wordSize != 0 {
+		return WithStage(StageExport, fmt.Errorf("calldata has %d bytes, which is not a verifyProof call", len(encoded)))
+	}
+	selector := verifyProofSelector(2*nbCommitments, nbInputs)
+	if !bytes.Equal(encoded[:4], selector) {
+		return WithStage(StageExport, fmt.Errorf("calldata selector %x does not match verifyProof %x", encoded[:4], selector))
+	}
+	encoded = encoded[4:]
+
+	proof := new(groth16_bn254.Proof)
+	points := []interface{ SetBytes([]byte) (int, error) }{&proof.Ar, &proof.Bs, &proof.Krs}
+	if nbCommitments > 0 {
+		proof.Commitments = make([]curve.G1Affine, nbCommitments)
+		for i := range proof.Commitments {
+			points = append(points, &proof.Commitments[i])
+		}
+		points = append(points, &proof.CommitmentPok)
+	}
+	for _, point := range points {
+		n, err := point.SetBytes(encoded)
+		if err != nil {
+			return WithStage(StageExport, fmt.Errorf("invalid proof in calldata: %w", err))
+		}
+		encoded = encoded[n:]
+	}
+
+	publicWitness, err := witness.New(ecc.BN254.ScalarField())
+	if err != nil {
+		return WithStage(StageWitness, err)
+	}
+	// The contract rejects public inputs that are not reduced, which the
+	// witness would otherwise reduce silently.
+	values := make(chan any, nbInputs)
+	for i := range nbInputs {
+		input := new(big.Int).SetBytes(encoded[i*wordSize : (i+1)*wordSize])
+		if input.Cmp(fr.Modulus()) >= 0 {
+			return WithStage(StageExport, fmt.Errorf("public input %d of the calldata is not below the scalar field modulus", i))
+		}
+		values <- input
+	}
+	close(values)
+	if err := publicWitness.Fill(nbInputs, 0, values); err != nil {
+		return WithStage(StageWitness, err)
+	}
+
+	return WithStage(StageVerify, groth16.Verify(proof, vk, publicWitness, backend.WithVerifierHashToFieldFunction(sha3.NewLegacyKeccak256())))
+}
+
+// verifyProofSelector returns the function selector of verifyProof for the
+// given number of commitment words and public inputs.
+func verifyProofSelector(nbCommitmentWords int, nbInputs int) []byte {
+	signature := fmt.Sprintf("verifyProof(uint256[8],uint256[%d])", nbInputs)
+	if nbCommitmentWords > 0 {
+		signature = fmt.Sprintf("verifyProof(uint256[8],uint256[%d],uint256[2],uint256[%d])", nbCommitmentWords, nbInputs)
+	}
+	hash := native.Keccak256([]byte(signature))
+	return append([]byte{}, hash[:4]...)
+}
+
+func hexWords(b []byte) []string {
+	words := make([]string, len(b)/wordSize)
+	for i := range words {
+		words[i] = "0x" + hex.EncodeToString(b[i*wordSize:(i+1)*wordSize])
+	}
+	return words
+}
@@ -0,0 +1,198 @@
+package whir
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math/big"
+	"reilabs/whir-verifier-circuit/native"
+	"strings"
+	"testing"
+
+	"github.com/consensys/gnark-crypto/ecc"
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/backend/groth16"
+	"github.com/consensys/gnark/backend/plonk"
+	plonk_bn254 "github.com/consensys/gnark/backend/plonk/bn254"
+	"github.com/consensys/gnark/backend/witness"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/frontend/cs/r1cs"
+	"github.com/consensys/gnark/frontend/cs/scs"
+	"github.com/consensys/gnark/test/unsafekzg"
+)
+
+// calldataCircuit has public inputs and a commitment, like the verifier
+// circuit, so that the calldata carries every part of a verifyProof call.
+type calldataCircuit struct {
+	X, Y frontend.Variable `gnark:",public"`
+	Z    frontend.Variable
+}
+
+func (c *calldataCircuit) Define(api frontend.API) error {
+	committed, err := api.Compiler().(frontend.Committer).Commit(c.Z)
+	if err != nil {
+		return err
+	}
+	api.AssertIsDifferent(committed, 0)
+	api.AssertIsEqual(api.Mul(c.X, c.Z), c.Y)
+	return nil
+}
+
+// proveCalldata proves a small circuit and returns a verifier holding its
+// verifying key and the calldata of the proof.
+func proveCalldata(t *testing.T) (*Verifier, *Calldata) {
+	t.Helper()
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), r1cs.NewBuilder, &calldataCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, vk, err := groth16.Setup(ccs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assignment := &calldataCircuit{X: 3, Y: 21, Z: 7}
+	witness, err := frontend.NewWitness(assignment, ecc.BN254.ScalarField())
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := groth16.Prove(ccs, pk, witness, proverOptions(BackendGroth16)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := formatCalldata(proof, assignment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Verifier{vk: vk}, c
+}
+
+// setInput replaces the i-th public input of the ABI encoded call.
+func setInput(t *testing.T, c *Calldata, i int, value *big.Int) *Calldata {
+	t.Helper()
+	encoded, err := hex.DecodeString(strings.TrimPrefix(c.Calldata, "0x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	offset := len(encoded) - (len(c.Input)-i)*wordSize
+	value.FillBytes(encoded[offset : offset+wordSize])
+	return &Calldata{Calldata: "0x" + hex.EncodeToString(encoded)}
+}
+
+func TestCalldataVerifies(t *testing.T) {
+	v, c := proveCalldata(t)
+	if len(c.Input) != 2 || len(c.Commitments) != 2 || len(c.CommitmentPok) != 2 {
+		t.Fatalf("calldata has %d inputs, %d commitment and %d proof of knowledge words", len(c.Input), len(c.Commitments), len(c.CommitmentPok))
+	}
+	if err := v.CheckCalldata(c); err != nil {
+		t.Fatalf("calldata of a valid proof is rejected: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := v.ExportSolidity(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "function verifyProof(") {
+		t.Error("exported verifier has no verifyProof function")
+	}
+}
+
+func TestCalldataRejectsWrongInput(t *testing.T) {
+	v, c := proveCalldata(t)
+	if err := v.CheckCalldata(setInput(t, c, 1, big.NewInt(22))); err == nil {
+		t.Error("calldata with a wrong public input is accepted")
+	}
+}
+
+func TestCalldataRejectsUnreducedInput(t *testing.T) {
+	v, c := proveCalldata(t)
+	unreduced := new(big.Int).Add(big.NewInt(21), fr.Modulus())
+	if err := v.CheckCalldata(setInput(t, c, 1, unreduced)); err == nil {
+		t.Error("calldata with a public input above the modulus is accepted")
+	}
+}
+
+// decodePlonkCalldata splits an encoded Verify(bytes,uint256[]) call into the
+// proof bytes and the public inputs.
+func decodePlonkCalldata(t *testing.T, c *Calldata) ([]byte, []*big.Int) {
+	t.Helper()
+	encoded, err := hex.DecodeString(strings.TrimPrefix(c.Calldata, "0x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	selector := native.Keccak256([]byte("Verify(bytes,uint256[])"))
+	if !bytes.Equal(encoded[:4], selector[:4]) {
+		t.Fatalf("calldata selector %x is not the one of Verify", encoded[:4])
+	}
+	args := encoded[4:]
+	word := func(offset int) int {
+		return int(new(big.Int).SetBytes(args[offset : offset+wordSize]).Int64())
+	}
+	proofOffset, inputsOffset := word(0), word(wordSize)
+	proof := args[proofOffset+wordSize : proofOffset+wordSize+word(proofOffset)]
+	inputs := make([]*big.Int, word(inputsOffset))
+	for i := range inputs {
+		offset := inputsOffset + (i+1)*wordSize
+		inputs[i] = new(big.Int).SetBytes(args[offset : offset+wordSize])
+	}
+	return proof, inputs
+}
+
+func TestPlonkCalldataVerifies(t *testing.T) {
+	ccs, err := frontend.Compile(ecc.BN254.ScalarField(), scs.NewBuilder, &calldataCircuit{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srs, srsLagrange, err := unsafekzg.NewSRS(ccs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pk, vk, err := plonk.Setup(ccs, srs, srsLagrange)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assignment := &calldataCircuit{X: 3, Y: 21, Z: 7}
+	fullWitness, err := frontend.NewWitness(assignment, ecc.BN254.ScalarField())
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof, err := plonk.Prove(ccs, pk, fullWitness, proverOptions(BackendPlonk)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := formatCalldata(proof, assignment)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proofBytes, inputs := decodePlonkCalldata(t, c)
+	if !bytes.Equal(proofBytes, proof.(*plonk_bn254.Proof).MarshalSolidity()) {
+		t.Error("calldata does not carry the Solidity encoding of the proof")
+	}
+	if len(inputs) != 2 || inputs[0].Int64() != 3 || inputs[1].Int64() != 21 {
+		t.Fatalf("calldata has public inputs %v, expected [3 21]", inputs)
+	}
+
+	// The exported contract derives the commitment challenges with the
+	// default hash-to-field of gnark, so the proof must verify without
+	// verifier options.
+	verify := func(inputs []*big.Int) error {
+		publicWitness, err := witness.New(ecc.BN254.ScalarField())
+		if err != nil {
+			t.Fatal(err)
+		}
+		values := make(chan any, len(inputs))
+		for _, input := range inputs {
+			values <- input
+		}
+		close(values)
+		if err := publicWitness.Fill(len(inputs), 0, values); err != nil {
+			t.Fatal(err)
+		}
+		return plonk.Verify(proof, vk, publicWitness)
+	}
+	if err := verify(inputs); err != nil {
+		t.Fatalf("calldata of a valid proof is rejected: %v", err)
+	}
+	if err := verify([]*big.Int{inputs[0], big.NewInt(22)}); err == nil {
+		t.Error("calldata with a wrong public input is accepted")
+	}
+}
@@ -0,0 +1,179 @@
+package whir
+
+import (
+	"fmt"
+	"math/big"
+	"reilabs/whir-verifier-circuit/poseidon2"
+	"reilabs/whir-verifier-circuit/typeConverters"
+	"slices"
+
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/math/uints"
+	gnark_nimue "github.com/reilabs/gnark-nimue"
+	"github.com/reilabs/gnark-nimue/hash"
+	skyscraper "github.com/reilabs/gnark-skyscraper"
+)
+
+// TranscriptSponge is a duplex sponge over field elements the Fiat-Shamir
+// transcript is replayed with, the duplex hash of gnark-nimue. Skyscraper,
+// Poseidon2 and MiMC implement it, while Keccak replays the transcript over
+// bytes instead.
+type TranscriptSponge interface {
+	hash.DuplexHash[frontend.Variable]
+}
+
+// newTranscriptSponge returns the sponge of a field hash.
+func newTranscriptSponge(api frontend.API, sc *skyscraper.Skyscraper, h Hash) (TranscriptSponge, error) {
+	switch h {
+	case HashSkyscraper, "":
+		return hash.NewSkyScraper(sc)
+	case HashPoseidon2:
+		return poseidon2.NewDuplex(api), nil
+	case HashMiMC:
+		m, err := newMiMC(api)
+		if err != nil {
+			return nil, err
+		}
+		return &mimcDuplex{h: m}, nil
+	}
+	return nil, fmt.Errorf("%q has no transcript sponge over field elements", h)
+}
+
+// challengeBytesPerScalar is the number of uniformly distributed bytes taken
+// from each squeezed BN254 scalar, as gnark-nimue does for Skyscraper.
+const challengeBytesPerScalar = 15
+
+// fieldArthur replays a transcript over a TranscriptSponge. It reads and
+// squeezes exactly like the field Arthur gnark-nimue builds for Skyscraper,
+// which does not take another sponge.
+type fieldArthur struct {
+	api        frontend.API
+	transcript []uints.U8
+	safe       *gnark_nimue.Safe[frontend.Variable, TranscriptSponge]
+}
+
+func newFieldArthur(api frontend.API, io []byte, transcript []uints.U8, sponge TranscriptSponge) (gnark_nimue.Arthur, error) {
+	safe, err := gnark_nimue.NewSafe[frontend.Variable](sponge, io)
+	if err != nil {
+		return nil, err
+	}
+	return &fieldArthur{api: api, transcript: transcript, safe: safe}, nil
+}
+
+func (a *fieldArthur) next(n int) ([]uints.U8, error) {
+	if len(a.transcript) < n {
+		return nil, fmt.Errorf("transcript exhausted: need %d bytes, %d left", n, len(a.transcript))
+	}
+	res := a.transcript[:n]
+	a.transcript = a.transcript[n:]
+	return res, nil
+}
+
+func (a *fieldArthur) FillNextBytes(out []uints.U8) error {
+	bytes, err := a.next(len(out))
+	if err != nil {
+		return err
+	}
+	copy(out, bytes)
+	for _, b := range out {
+		if err := a.safe.Absorb([]frontend.Variable{b.Val}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *fieldArthur) FillChallengeBytes(out []uints.U8) error {
+	if len(out) == 0 {
+		return nil
+	}
+	lenGood := min(len(out), challengeBytesPerScalar)
+	tmp := make([]frontend.Variable, 1)
+	for i := range (len(out) + lenGood - 1) / lenGood {
+		if err := a.FillChallengeScalars(tmp); err != nil {
+			return err
+		}
+		bits := a.api.ToBinary(tmp[0])
+		for k := range lenGood {
+			o := i*lenGood + k
+			if o >= len(out) {
+				break
+			}
+			out[o] = uints.U8{Val: a.api.FromBinary(bits[8*k : 8*k+8]...)}
+		}
+	}
+	return nil
+}
+
+func (a *fieldArthur) FillNextScalars(out []frontend.Variable) error {
+	wordSize := (a.api.Compiler().FieldBitLen() + 7) / 8
+	for i := range out {
+		bytes, err := a.next(wordSize)
+		if err != nil {
+			return err
+		}
+		out[i] = typeConverters.LittleEndianFromUints(a.api, bytes)
+	}
+	return a.safe.Absorb(out)
+}
+
+func (a *fieldArthur) FillChallengeScalars(out []frontend.Variable) error {
+	return a.safe.Squeeze(out)
+}
+
+func (a *fieldArthur) PrintState(api frontend.API) {
+	api.Println(fmt.Sprintf("remaining transcript bytes: %d", len(a.transcript)))
+	a.safe.PrintState(api)
+}
+
+// mimcDuplex is a duplex sponge of width 2 and rate 1 over the MiMC hash of
+// gnark's std. MiMC is a hash rather than a permutation, so the state (r, c)
+// is permuted into (MiMC(r, c), MiMC(c, r)); a WHIR prover with a MiMC
+// transcript has to build its sponge the same way.
+//
+// With a rate of 1 every squeeze permutes, so only the absorb position is
+// kept.
+type mimcDuplex struct {
+	h         mimcHash
+	state     [2]frontend.Variable
+	absorbPos int
+}
+
+// Initialize puts the tag, read as a little-endian integer, in the capacity.
+func (s *mimcDuplex) Initialize(iv [32]byte) {
+	slices.Reverse(iv[:])
+	s.state = [2]frontend.Variable{0, new(big.Int).SetBytes(iv[:])}
+	s.absorbPos = 0
+}
+
+func (s *mimcDuplex) permute() {
+	s.state = [2]frontend.Variable{s.h.Sum(s.state[0], s.state[1]), s.h.Sum(s.state[1], s.state[0])}
+}
+
+func (s *mimcDuplex) Absorb(input []frontend.Variable) {
+	for _, x := range input {
+		if s.absorbPos == 1 {
+			s.permute()
+		}
+		s.state[0] = x
+		s.absorbPos = 1
+	}
+}
+
+func (s *mimcDuplex) Squeeze(output []frontend.Variable) {
+	for i := range output {
+		s.permute()
+		s.absorbPos = 0
+		output[i] = s.state[0]
+	}
+}
+
+func (s *mimcDuplex) Ratchet() {
+	s.permute()
+	s.state[0] = 0
+}
+
+func (s *mimcDuplex) PrintState(api frontend.API) {
+	api.Println(fmt.Sprintf("absorbPos %d", s.absorbPos))
+	api.Println(s.state[:]...)
+}
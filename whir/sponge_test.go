@@ -0,0 +1,25 @@
+package whir
+
+import (
+	"testing"
+)
+
+// The test prover draws its challenges with the native sponges, so a proof
+// verifies only if the sponge of the circuit squeezes the same challenges.
+func TestTranscriptSponges(t *testing.T) {
+	for _, hash := range []Hash{HashSkyscraper, HashPoseidon2, HashMiMC} {
+		cfg := testParams(squareProgram.logConstraints, squareProgram.logVars())
+		cfg.TranscriptHash = string(hash)
+		proof, cfg, r1cs, interner := proveTestWHIR(t, squareProgram, cfg, false)
+		v, err := NewVerifier(proof, cfg, r1cs, interner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := v.Check(); err != nil {
+			t.Errorf("%s: %v", hash, err)
+		}
+		if err := isSolved(v); err != nil {
+			t.Errorf("%s: %v", hash, err)
+		}
+	}
+}
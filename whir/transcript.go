@@ -0,0 +1,110 @@
+package whir
+
+import (
+	"fmt"
+	"math/big"
+	"reilabs/whir-verifier-circuit/native"
+	"reilabs/whir-verifier-circuit/typeConverters"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/hash/sha3"
+	"github.com/consensys/gnark/std/math/uints"
+	skyscraper "github.com/reilabs/gnark-skyscraper"
+)
+
+// TranscriptMode selects how the WHIR transcript is exposed to the Groth16
+// verifier.
+type TranscriptMode string
+
+const (
+	// TranscriptPublic makes every transcript byte a public input.
+	TranscriptPublic TranscriptMode = "public"
+	// TranscriptSkyscraper keeps the transcript private and exposes a single
+	// Skyscraper digest of it.
+	TranscriptSkyscraper TranscriptMode = "skyscraper"
+	// TranscriptKeccak keeps the transcript private and exposes its
+	// Keccak-256 hash as two 128 bit public inputs, high half first.
+	TranscriptKeccak TranscriptMode = "keccak"
+)
+
+// skyscraperChunkSize is the number of transcript bytes packed into each field
+// element absorbed by the Skyscraper digest.
+const skyscraperChunkSize = 31
+
+func ParseTranscriptMode(s string) (TranscriptMode, error) {
+	switch mode := TranscriptMode(s); mode {
+	case TranscriptPublic, TranscriptSkyscraper, TranscriptKeccak:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown transcript mode %q, expected public, skyscraper or keccak", s)
+}
+
+// transcript returns the transcript bytes, public or private depending on
+// the mode.
+func (circuit *Circuit) transcript() []uints.U8 {
+	if circuit.TranscriptMode == TranscriptPublic {
+		return circuit.Transcript
+	}
+	return circuit.PrivateTranscript
+}
+
+// checkTranscriptDigest asserts that the public digest is the hash of the
+// private transcript.
+func checkTranscriptDigest(api frontend.API, sc *skyscraper.Skyscraper, circuit *Circuit) error {
+	var digest []frontend.Variable
+	switch circuit.TranscriptMode {
+	case TranscriptPublic:
+		return nil
+	case TranscriptSkyscraper:
+		digest = []frontend.Variable{skyscraperDigest(api, sc, circuit.PrivateTranscript)}
+	case TranscriptKeccak:
+		h, err := sha3.NewLegacyKeccak256(api)
+		if err != nil {
+			return err
+		}
+		h.Write(circuit.PrivateTranscript)
+		sum := h.Sum()
+		digest = []frontend.Variable{
+			typeConverters.BigEndianFromUints(api, sum[:16]),
+			typeConverters.BigEndianFromUints(api, sum[16:]),
+		}
+	default:
+		return fmt.Errorf("unknown transcript mode %q", circuit.TranscriptMode)
+	}
+
+	if len(digest) != len(circuit.TranscriptDigest) {
+		return fmt.Errorf("transcript digest has %d elements, expected %d", len(circuit.TranscriptDigest), len(digest))
+	}
+	for i := range digest {
+		api.AssertIsEqual(digest[i], circuit.TranscriptDigest[i])
+	}
+	return nil
+}
+
+func skyscraperDigest(api frontend.API, sc *skyscraper.Skyscraper, transcript []uints.U8) frontend.Variable {
+	digest := frontend.Variable(0)
+	for start := 0; start < len(transcript); start += skyscraperChunkSize {
+		end := min(start+skyscraperChunkSize, len(transcript))
+		digest = sc.Compress(digest, typeConverters.LittleEndianFromUints(api, transcript[start:end]))
+	}
+	return digest
+}
+
+// TranscriptDigest computes the public inputs that stand in for transcript
+// under the given mode. It returns nil for TranscriptPublic.
+func TranscriptDigest(mode TranscriptMode, transcript []byte) []*big.Int {
+	switch mode {
+	case TranscriptSkyscraper:
+		var digest fr.Element
+		for start := 0; start < len(transcript); start += skyscraperChunkSize {
+			end := min(start+skyscraperChunkSize, len(transcript))
+			digest = native.SkyscraperCompress(digest, native.FromLittleEndian(transcript[start:end]))
+		}
+		return []*big.Int{digest.BigInt(new(big.Int))}
+	case TranscriptKeccak:
+		sum := native.Keccak256(transcript)
+		return []*big.Int{new(big.Int).SetBytes(sum[:16]), new(big.Int).SetBytes(sum[16:])}
+	}
+	return nil
+}
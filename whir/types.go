@@ -0,0 +1,90 @@
+package whir
+
+type KeccakDigest struct {
+	KeccakDigest [32]uint8
+}
+
+type Fp256 struct {
+	Limbs [4]uint64
+}
+
+// MultiPath holds the authentication paths of the opened leaves, with the
+// arity-1 siblings of every level next to each other.
+type MultiPath[Digest any] struct {
+	LeafSiblingHashes      []Digest
+	AuthPathsPrefixLengths []uint64
+	AuthPathsSuffixes      [][]Digest
+	LeafIndexes            []uint64
+}
+
+type ProofElement struct {
+	A MultiPath[KeccakDigest]
+	B [][]Fp256
+}
+
+type ProofObject struct {
+	FirstRoundPaths              []ProofElement `json:"round0_merkle_paths"`
+	MerklePaths                  []ProofElement `json:"merkle_paths"`
+	StatementValuesAtRandomPoint []Fp256        `json:"statement_values_at_random_point"`
+}
+
+type Config struct {
+	LogNumConstraints    int      `json:"log_num_constraints"`
+	NRounds              int      `json:"n_rounds"`
+	NVars                int      `json:"n_vars"`
+	FoldingFactor        []int    `json:"folding_factor"`
+	OODSamples           []int    `json:"ood_samples"`
+	NumQueries           []int    `json:"num_queries"`
+	PowBits              []int    `json:"pow_bits"`
+	FinalQueries         int      `json:"final_queries"`
+	FinalPowBits         int      `json:"final_pow_bits"`
+	FinalFoldingPowBits  int      `json:"final_folding_pow_bits"`
+	DomainGenerator      string   `json:"domain_generator"`
+	Rate                 int      `json:"rate"`
+	IOPattern            string   `json:"io_pattern"`
+	Transcript           []byte   `json:"transcript"`
+	TranscriptLen        int      `json:"transcript_len"`
+	StatementEvaluations []string `json:"statement_evaluations"`
+	// The hashes the prover is configured with, Skyscraper when left out.
+	MerkleHash     string `json:"merkle_hash,omitempty"`
+	TranscriptHash string `json:"transcript_hash,omitempty"`
+	PoWHash        string `json:"pow_hash,omitempty"`
+	// LeafHash is how the leaves of the Merkle trees are hashed, the default
+	// of the Merkle hash when left out.
+	LeafHash string `json:"leaf_hash,omitempty"`
+	// MerkleArity is the number of children of every node of the Merkle
+	// trees, 2 when left out.
+	MerkleArity int `json:"merkle_arity,omitempty"`
+}
+
+type Item struct {
+	Constraint int    `json:"constraint"`
+	Signal     int    `json:"signal"`
+	Value      string `json:"value"`
+}
+
+type SparseMatrix struct {
+	Rows       uint64   `json:"rows"`
+	Cols       uint64   `json:"cols"`
+	RowIndices []uint64 `json:"row_indices"`
+	ColIndices []uint64 `json:"col_indices"`
+	Values     []uint64 `json:"values"`
+}
+
+type Interner struct {
+	Values []Fp256 `json:"values"`
+}
+
+type InternerAsString struct {
+	Values string `json:"values"`
+}
+
+type R1CS struct {
+	PublicInputs uint64           `json:"public_inputs"`
+	Witnesses    uint64           `json:"witnesses"`
+	Constraints  uint64           `json:"constraints"`
+	Interner     InternerAsString `json:"interner"`
+	A            SparseMatrix     `json:"a"`
+	B            SparseMatrix     `json:"b"`
+	C            SparseMatrix     `json:"c"`
+}
@@ -0,0 +1,514 @@
+package whir
+
+import (
+	"fmt"
+	"math/big"
+	"math/bits"
+	"reflect"
+	"reilabs/whir-verifier-circuit/native"
+	"reilabs/whir-verifier-circuit/utilities"
+	"slices"
+
+	"github.com/consensys/gnark-crypto/ecc/bn254/fr"
+
+	"github.com/consensys/gnark/frontend"
+	"github.com/consensys/gnark/std/lookup/logderivlookup"
+	"github.com/consensys/gnark/std/math/uints"
+	skyscraper "github.com/reilabs/gnark-skyscraper"
+)
+
+// UniversalBounds fix the program dependent parts of the circuit shape, so
+// that one constraint system and key pair serve every R1CS program within
+// them.
+//
+// The sizes of the R1CS fix the number of sumcheck rounds, the WHIR rounds
+// and the number of queries, and with them the transcript layout. The
+// circuit therefore holds a verifier for each of the Shapes, the params of
+// every instance size it admits, and a private selector enables the one the
+// proof has while the others run unconstrained. The matrix entries are padded
+// into NonZeros slots by the circuit.
+type UniversalBounds struct {
+	LogConstraints int
+	LogVars        int
+	// NonZeros is the number of entries of each matrix.
+	NonZeros int
+	// Shapes are the params of the admitted sizes, stripped by ShapeParams,
+	// each with at most 2^LogConstraints constraints and 2^LogVars witness
+	// entries. When left out the circuit admits the size of the proof only.
+	// Every shape is a full verifier, so the circuit grows linearly with
+	// them, and a size that is not listed is not admitted within the bounds.
+	Shapes []Config `json:",omitempty"`
+}
+
+// MatrixEntry is a matrix entry given as a circuit input. The universal
+// circuit uses it in place of the constant MatrixCell, and pads the matrices
+// with zero entries.
+type MatrixEntry struct {
+	Row    frontend.Variable
+	Column frontend.Variable
+	Value  frontend.Variable
+}
+
+// WithUniversal builds the universal circuit for the given bounds instead of
+// a circuit specialised to the R1CS program.
+func WithUniversal(bounds UniversalBounds) Option {
+	return func(s *settings) {
+		s.Universal = &bounds
+	}
+}
+
+// WithCommittedMatrices makes the matrices of the universal circuit private
+// and exposes a single Skyscraper digest of them instead, see MatrixDigest.
+// It requires WithUniversal.
+func WithCommittedMatrices() Option {
+	return func(s *settings) {
+		s.CommitMatrices = true
+	}
+}
+
+func (b UniversalBounds) validate(cfg Config, r1cs R1CS) error {
+	if b.LogConstraints <= 0 || b.LogVars <= 0 || b.NonZeros <= 0 {
+		return fmt.Errorf("universal bounds must be positive, got %d, %d and %d", b.LogConstraints, b.LogVars, b.NonZeros)
+	}
+	for k, shape := range b.shapes(cfg) {
+		if shape.LogNumConstraints > b.LogConstraints {
+			return fmt.Errorf("shape %d has 2^%d constraints, the universal circuit allows 2^%d", k, shape.LogNumConstraints, b.LogConstraints)
+		}
+		if shape.NVars > b.LogVars {
+			return fmt.Errorf("shape %d has 2^%d witness entries, the universal circuit allows 2^%d", k, shape.NVars, b.LogVars)
+		}
+	}
+	for i, matrix := range []SparseMatrix{r1cs.A, r1cs.B, r1cs.C} {
+		if len(matrix.Values) > b.NonZeros {
+			return fmt.Errorf("matrix %c has %d entries, the universal circuit allows %d", "ABC"[i], len(matrix.Values), b.NonZeros)
+		}
+	}
+	return nil
+}
+
+// shapes returns the admitted shapes, the one of cfg when none are given.
+func (b UniversalBounds) shapes(cfg Config) []Config {
+	if len(b.Shapes) == 0 {
+		return []Config{ShapeParams(cfg)}
+	}
+	return b.Shapes
+}
+
+// ShapeParams strips the params of a proof down to the shape of its size,
+// leaving out the transcript and the values of the statement evaluations.
+func ShapeParams(cfg Config) Config {
+	cfg.Transcript = nil
+	cfg.StatementEvaluations = make([]string, len(cfg.StatementEvaluations))
+	for i := range cfg.StatementEvaluations {
+		cfg.StatementEvaluations[i] = "0"
+	}
+	return cfg
+}
+
+// emptyProof returns a proof of the given shape opening leaf 0 everywhere,
+// with zero data, which gives the layout of the shape to buildShapeCircuits
+// and the witness of a shape the proof does not have.
+func emptyProof(shape Config, batchSize int) (ProofObject, error) {
+	arity, err := merkleArity(shape)
+	if err != nil {
+		return ProofObject{}, err
+	}
+	foldingFactor, _ := foldingFactors(shape)
+	if len(foldingFactor) < shape.NRounds+1 {
+		return ProofObject{}, fmt.Errorf("shape has %d folding factors for %d rounds", len(foldingFactor), shape.NRounds)
+	}
+	digitBits := bits.TrailingZeros(uint(arity))
+	paths := func(domainSize int, folding int) (ProofElement, error) {
+		treeBits := bits.Len(uint(domainSize>>folding)) - 1
+		if treeBits < digitBits || treeBits%digitBits != 0 {
+			return ProofElement{}, fmt.Errorf("a tree of 2^%d leaves has no arity %d levels", treeBits, arity)
+		}
+		return ProofElement{
+			A: MultiPath[KeccakDigest]{
+				LeafSiblingHashes:      make([]KeccakDigest, arity-1),
+				AuthPathsPrefixLengths: []uint64{0},
+				AuthPathsSuffixes:      [][]KeccakDigest{make([]KeccakDigest, (treeBits/digitBits-1)*(arity-1))},
+				LeafIndexes:            []uint64{0},
+			},
+			B: [][]Fp256{make([]Fp256, 1<<folding)},
+		}, nil
+	}
+
+	proof := ProofObject{StatementValuesAtRandomPoint: make([]Fp256, len(shape.StatementEvaluations))}
+	domainSize := startingDomainSize(shape)
+	for range batchSize {
+		element, err := paths(domainSize, foldingFactor[0])
+		if err != nil {
+			return ProofObject{}, err
+		}
+		proof.FirstRoundPaths = append(proof.FirstRoundPaths, element)
+	}
+	for i := range shape.NRounds {
+		element, err := paths(domainSize>>(i+1), foldingFactor[i+1])
+		if err != nil {
+			return ProofObject{}, err
+		}
+		proof.MerklePaths = append(proof.MerklePaths, element)
+	}
+	return proof, nil
+}
+
+// universalPaths returns the shape of the paths of proof once padded.
+func universalPaths(proof ProofObject, cfg Config) ([]pathsShape, []pathsShape) {
+	firstRound, merkle := shapeOfPaths(proof.FirstRoundPaths), shapeOfPaths(proof.MerklePaths)
+	for i := range firstRound {
+		firstRound[i].Leaves = paddedOpenings(cfg, true, i)
+	}
+	for i := range merkle {
+		merkle[i].Leaves = paddedOpenings(cfg, false, i)
+	}
+	return firstRound, merkle
+}
+
+// buildUniversalCircuits is buildCircuits for WithUniversal. The proof is
+// verified by the shape its params have, and every other shape is given the
+// witness of an empty proof.
+func buildUniversalCircuits(proof ProofObject, cfg Config, r1cs R1CS, interner Interner, s settings) (Circuit, Circuit, error) {
+	bounds := *s.Universal
+	if err := bounds.validate(cfg, r1cs); err != nil {
+		return Circuit{}, Circuit{}, err
+	}
+	shapes := bounds.shapes(cfg)
+	active := slices.IndexFunc(shapes, func(shape Config) bool {
+		return reflect.DeepEqual(shape, ShapeParams(cfg))
+	})
+	if active < 0 {
+		return Circuit{}, Circuit{}, fmt.Errorf("the params of the proof match none of the %d shapes of the universal circuit", len(shapes))
+	}
+
+	proofCircuit, proofAssignment, err := buildShapeCircuits(proof, cfg, r1cs, interner, s)
+	if err != nil {
+		return Circuit{}, Circuit{}, err
+	}
+	circuit, assignment := proofCircuit.sharedInputs(), proofAssignment.sharedInputs()
+	circuit.UniversalLogVars, assignment.UniversalLogVars = bounds.LogVars, bounds.LogVars
+
+	// The empty proofs only give the layout of their shape, so their
+	// matrices and transcript digest are not worth computing.
+	empty := s
+	empty.CommitMatrices = false
+	empty.TranscriptMode = TranscriptPublic
+	transcriptLen := 0
+	for k, shape := range shapes {
+		shapeProof, err := emptyProof(shape, len(proof.FirstRoundPaths))
+		if err != nil {
+			return Circuit{}, Circuit{}, fmt.Errorf("shape %d: %w", k, err)
+		}
+		shape.Transcript = make([]byte, shape.TranscriptLen)
+		shapeCircuit, shapeAssignment, err := buildShapeCircuits(shapeProof, shape, r1cs, interner, empty)
+		if err != nil {
+			return Circuit{}, Circuit{}, fmt.Errorf("shape %d: %w", k, err)
+		}
+		selector := 0
+		if k == active {
+			firstRound, merkle := universalPaths(proof, cfg)
+			shapeFirstRound, shapeMerkle := universalPaths(shapeProof, shape)
+			if !reflect.DeepEqual(firstRound, shapeFirstRound) || !reflect.DeepEqual(merkle, shapeMerkle) {
+				return Circuit{}, Circuit{}, fmt.Errorf("the proof opens paths of %v and %v, its params give %v and %v", firstRound, merkle, shapeFirstRound, shapeMerkle)
+			}
+			shapeAssignment, selector = proofAssignment, 1
+		}
+		shapeCircuit, shapeAssignment = shapeCircuit.withoutSharedInputs(), shapeAssignment.withoutSharedInputs()
+		shapeCircuit.TranscriptMode, shapeAssignment.TranscriptMode = s.TranscriptMode, s.TranscriptMode
+		circuit.Shapes = append(circuit.Shapes, shapeCircuit)
+		assignment.Shapes = append(assignment.Shapes, shapeAssignment)
+		circuit.ShapeSelector = append(circuit.ShapeSelector, nil)
+		assignment.ShapeSelector = append(assignment.ShapeSelector, selector)
+		transcriptLen = max(transcriptLen, shape.TranscriptLen)
+	}
+
+	// The shapes read prefixes of a transcript as long as the longest of
+	// them, padded with zeros.
+	contTranscript := make([]uints.U8, transcriptLen)
+	transcript := append([]uints.U8{}, assignment.transcript()...)
+	for len(transcript) < transcriptLen {
+		transcript = append(transcript, uints.NewU8(0))
+	}
+	if s.TranscriptMode == TranscriptPublic {
+		circuit.Transcript, assignment.Transcript = contTranscript, transcript
+	} else {
+		circuit.PrivateTranscript, assignment.PrivateTranscript = contTranscript, transcript
+	}
+	return circuit, assignment, nil
+}
+
+// sharedInputs returns the universal circuit holding the inputs of circuit
+// that all of its shapes share.
+func (circuit Circuit) sharedInputs() Circuit {
+	return Circuit{
+		Universal:         true,
+		TranscriptMode:    circuit.TranscriptMode,
+		Transcript:        circuit.Transcript,
+		PrivateTranscript: circuit.PrivateTranscript,
+		TranscriptDigest:  circuit.TranscriptDigest,
+		PublicInputs:      circuit.PublicInputs,
+		UniversalMatrixA:  circuit.UniversalMatrixA,
+		UniversalMatrixB:  circuit.UniversalMatrixB,
+		UniversalMatrixC:  circuit.UniversalMatrixC,
+		CommitMatrices:    circuit.CommitMatrices,
+		PrivateMatrixA:    circuit.PrivateMatrixA,
+		PrivateMatrixB:    circuit.PrivateMatrixB,
+		PrivateMatrixC:    circuit.PrivateMatrixC,
+		MatrixDigest:      circuit.MatrixDigest,
+	}
+}
+
+// withoutSharedInputs returns circuit as a shape of the universal circuit,
+// the inverse of Circuit.shape.
+func (circuit Circuit) withoutSharedInputs() Circuit {
+	circuit.TranscriptLen = len(circuit.transcript())
+	circuit.Transcript, circuit.PrivateTranscript, circuit.TranscriptDigest = nil, nil, nil
+	circuit.PublicInputs = nil
+	circuit.UniversalMatrixA, circuit.UniversalMatrixB, circuit.UniversalMatrixC = nil, nil, nil
+	circuit.PrivateMatrixA, circuit.PrivateMatrixB, circuit.PrivateMatrixC = nil, nil, nil
+	circuit.MatrixDigest = nil
+	circuit.CommitMatrices = false
+	return circuit
+}
+
+// shape returns the k-th shape given the inputs it shares with the universal
+// circuit: the prefix of the transcript it reads, the transcript digest, the
+// public inputs and the matrices, which the shape takes as public ones.
+func (circuit *Circuit) shape(k int) (*Circuit, error) {
+	shape := circuit.Shapes[k]
+	transcript := circuit.transcript()
+	if shape.TranscriptLen > len(transcript) {
+		return nil, fmt.Errorf("shape %d reads %d transcript bytes of %d", k, shape.TranscriptLen, len(transcript))
+	}
+	if shape.TranscriptMode == TranscriptPublic {
+		shape.Transcript = transcript[:shape.TranscriptLen]
+	} else {
+		shape.PrivateTranscript = transcript[:shape.TranscriptLen]
+	}
+	shape.TranscriptDigest = circuit.TranscriptDigest
+	shape.PublicInputs = circuit.PublicInputs
+	matrices := circuit.matrixEntries()
+	shape.UniversalMatrixA, shape.UniversalMatrixB, shape.UniversalMatrixC = matrices[0], matrices[1], matrices[2]
+	return &shape, nil
+}
+
+// defineShapes is Define for the universal circuit. Every shape verifies the
+// proof through a gatedAPI enabled by its selector, of which exactly one is
+// set, so the proof has to pass the verifier of one of the shapes.
+func (circuit *Circuit) defineShapes(api frontend.API) error {
+	if len(circuit.ShapeSelector) != len(circuit.Shapes) {
+		return fmt.Errorf("%d shape selectors for %d shapes", len(circuit.ShapeSelector), len(circuit.Shapes))
+	}
+	selected := frontend.Variable(0)
+	for _, selector := range circuit.ShapeSelector {
+		api.AssertIsBoolean(selector)
+		selected = api.Add(selected, selector)
+	}
+	api.AssertIsEqual(selected, 1)
+
+	if circuit.CommitMatrices {
+		if err := checkMatrixDigest(api, skyscraper.NewSkyscraper(api, 2), circuit); err != nil {
+			return err
+		}
+	}
+
+	for k := range circuit.Shapes {
+		gated := gatedAPI{API: api, active: circuit.ShapeSelector[k]}
+		shape, err := circuit.shape(k)
+		if err != nil {
+			return err
+		}
+		// The transcript past the one of the shape is padding.
+		for _, b := range circuit.transcript()[shape.TranscriptLen:] {
+			gated.AssertIsEqual(b.Val, 0)
+		}
+		// The shapes the proof does not have look up row and column 0,
+		// which are in their eq tables whatever the matrices hold.
+		shape.UniversalMatrixA = selectEntries(gated, shape.UniversalMatrixA)
+		shape.UniversalMatrixB = selectEntries(gated, shape.UniversalMatrixB)
+		shape.UniversalMatrixC = selectEntries(gated, shape.UniversalMatrixC)
+		if err := shape.verify(gated); err != nil {
+			return fmt.Errorf("shape %d: %w", k, err)
+		}
+	}
+	return nil
+}
+
+// selectEntries returns the entries with their positions zeroed unless the
+// api is active.
+func selectEntries(api gatedAPI, entries []MatrixEntry) []MatrixEntry {
+	res := make([]MatrixEntry, len(entries))
+	for i, entry := range entries {
+		res[i] = MatrixEntry{
+			Row:    api.Select(api.active, entry.Row, 0),
+			Column: api.Select(api.active, entry.Column, 0),
+			Value:  entry.Value,
+		}
+	}
+	return res
+}
+
+// matrixEntries pads cells with zero entries to n entries, returning the
+// zero-valued entries for compilation and the assigned ones.
+func matrixEntries(cells []MatrixCell, n int) ([]MatrixEntry, []MatrixEntry) {
+	container := make([]MatrixEntry, n)
+	assignment := make([]MatrixEntry, n)
+	for i := range assignment {
+		assignment[i] = MatrixEntry{Row: 0, Column: 0, Value: 0}
+		if i < len(cells) {
+			assignment[i] = MatrixEntry{Row: cells[i].row, Column: cells[i].column, Value: cells[i].value}
+		}
+	}
+	return container, assignment
+}
+
+// evaluateUniversalMatrixExtension is evaluateR1CSMatrixExtension for
+// matrices given as inputs. The entries look up their eq values, and the
+// padding entries have value zero, so they do not contribute.
+func evaluateUniversalMatrixExtension(api frontend.API, circuit *Circuit, rowRand []frontend.Variable, colRand []frontend.Variable) []frontend.Variable {
+	rowTable := logderivlookup.New(api)
+	for _, x := range calculateEQOverBooleanHypercube(api, rowRand) {
+		rowTable.Insert(x)
+	}
+	colTable := logderivlookup.New(api)
+	for _, x := range calculateEQOverBooleanHypercube(api, colRand) {
+		colTable.Insert(x)
+	}
+
+	matrices := circuit.matrixEntries()
+	ans := make([]frontend.Variable, len(matrices))
+	for m, matrix := range matrices {
+		rows := make([]frontend.Variable, len(matrix))
+		columns := make([]frontend.Variable, len(matrix))
+		for i := range matrix {
+			rows[i] = matrix[i].Row
+			columns[i] = matrix[i].Column
+		}
+		rowEval := rowTable.Lookup(rows...)
+		colEval := colTable.Lookup(columns...)
+
+		ans[m] = frontend.Variable(0)
+		for i := range matrix {
+			ans[m] = api.Add(ans[m], api.Mul(matrix[i].Value, api.Mul(rowEval[i], colEval[i])))
+		}
+	}
+	return ans
+}
+
+// paddedOpenings returns the number of leaves the universal circuit opens in
+// each round of paths: the number of queries, which bounds the number of
+// distinct leaves the prover opens.
+func paddedOpenings(cfg Config, firstRound bool, round int) int {
+	// The first round paths answer the queries of round 0, and the paths of
+	// round i those of round i+1.
+	next := round + 1
+	if firstRound {
+		next = 0
+	}
+	if next < len(cfg.NumQueries) {
+		return cfg.NumQueries[next]
+	}
+	return cfg.FinalQueries
+}
+
+// padOpenings pads the openings of every round to the number of queries by
+// repeating the last one.
+func padOpenings(object *MerkleObject, cfg Config, firstRound bool) error {
+	for i := range object.Leaves {
+		n := paddedOpenings(cfg, firstRound, i)
+		last := len(object.Leaves[i]) - 1
+		if last+1 > n {
+			return fmt.Errorf("paths %d open %d leaves, more than the %d queries", i, last+1, n)
+		}
+		for range n - last - 1 {
+			object.AuthPaths[i] = append(object.AuthPaths[i], copyAuthPath(object.AuthPaths[i][last]))
+			object.Leaves[i] = append(object.Leaves[i], append([]frontend.Variable{}, object.Leaves[i][last]...))
+			object.LeafSiblingHashes[i] = append(object.LeafSiblingHashes[i], append([]uints.U8{}, object.LeafSiblingHashes[i][last]...))
+			object.LeafIndexes[i] = append(object.LeafIndexes[i], object.LeafIndexes[i][last])
+			object.ContainerAuthPaths[i] = append(object.ContainerAuthPaths[i], copyAuthPath(object.ContainerAuthPaths[i][last]))
+			object.ContainerLeaves[i] = append(object.ContainerLeaves[i], make([]frontend.Variable, len(object.ContainerLeaves[i][last])))
+			object.ContainerLeafSiblingHashes[i] = append(object.ContainerLeafSiblingHashes[i], make([]uints.U8, len(object.ContainerLeafSiblingHashes[i][last])))
+			object.ContainerLeafIndexes[i] = append(object.ContainerLeafIndexes[i], uints.U64{})
+		}
+	}
+	return nil
+}
+
+// copyAuthPath copies path, so that the padded openings do not share the
+// inputs of the opening they repeat.
+func copyAuthPath(path [][]uints.U8) [][]uints.U8 {
+	res := make([][]uints.U8, len(path))
+	for i := range path {
+		res[i] = append([]uints.U8{}, path[i]...)
+	}
+	return res
+}
+
+// maskPaddedOpenings zeroes the combination randomness of the openings
+// padOpenings adds, so that they do not count towards the round claims. The
+// padding is the tail of repeats of the last opening, which IsSubset asserts
+// is the only place an opening may repeat, so a query the prover opened
+// twice elsewhere is rejected rather than masked.
+func maskPaddedOpenings(api frontend.API, uapi *uints.BinaryField[uints.U64], randomness []frontend.Variable, leafIndexes []uints.U64) {
+	indexes := make([]frontend.Variable, len(leafIndexes))
+	for i := range leafIndexes {
+		indexes[i] = uapi.ToValue(leafIndexes[i])
+	}
+	for i, tail := range utilities.PaddedTail(api, indexes) {
+		randomness[i] = api.Select(tail, 0, randomness[i])
+	}
+}
+
+// matrixEntries returns the matrices of the universal circuit, public or
+// private depending on CommitMatrices.
+func (circuit *Circuit) matrixEntries() [][]MatrixEntry {
+	if circuit.CommitMatrices {
+		return [][]MatrixEntry{circuit.PrivateMatrixA, circuit.PrivateMatrixB, circuit.PrivateMatrixC}
+	}
+	return [][]MatrixEntry{circuit.UniversalMatrixA, circuit.UniversalMatrixB, circuit.UniversalMatrixC}
+}
+
+// checkMatrixDigest asserts that the public digest is the hash of the private
+// matrices. Every entry is absorbed as its packed position
+// row·2^UniversalLogVars + column followed by its value, so the digest covers the CSR
+// data of A, B and C including the padding. The positions are bounded by the
+// eq table lookups, so the packing is injective.
+func checkMatrixDigest(api frontend.API, sc *skyscraper.Skyscraper, circuit *Circuit) error {
+	if !circuit.CommitMatrices {
+		return nil
+	}
+	if len(circuit.MatrixDigest) != 1 {
+		return fmt.Errorf("matrix digest has %d elements, expected 1", len(circuit.MatrixDigest))
+	}
+	digest := frontend.Variable(0)
+	for _, matrix := range circuit.matrixEntries() {
+		for _, entry := range matrix {
+			digest = sc.Compress(digest, api.Add(api.Mul(entry.Row, new(big.Int).Lsh(big.NewInt(1), uint(circuit.UniversalLogVars))), entry.Column))
+			digest = sc.Compress(digest, entry.Value)
+		}
+	}
+	api.AssertIsEqual(digest, circuit.MatrixDigest[0])
+	return nil
+}
+
+// MatrixDigest computes the public input that stands in for the matrices of
+// the R1CS under WithCommittedMatrices, for the given universal bounds.
+func MatrixDigest(r1cs R1CS, interner Interner, bounds UniversalBounds) *big.Int {
+	return matrixDigest(bounds, matrixCells(r1cs.A, interner), matrixCells(r1cs.B, interner), matrixCells(r1cs.C, interner))
+}
+
+func matrixDigest(bounds UniversalBounds, matrices ...[]MatrixCell) *big.Int {
+	var digest fr.Element
+	for _, cells := range matrices {
+		for i := range bounds.NonZeros {
+			var position, value fr.Element
+			if i < len(cells) {
+				position.SetBigInt(new(big.Int).Add(new(big.Int).Lsh(big.NewInt(int64(cells[i].row)), uint(bounds.LogVars)), big.NewInt(int64(cells[i].column))))
+				value.SetBigInt(cells[i].value)
+			}
+			digest = native.SkyscraperCompress(digest, position)
+			digest = native.SkyscraperCompress(digest, value)
+		}
+	}
+	return digest.BigInt(new(big.Int))
+}
@@ -0,0 +1,87 @@
+package whir
+
+import (
+	"testing"
+
+	"github.com/consensys/gnark/frontend"
+)
+
+// universalBounds admits the sizes of the test programs.
+func universalBounds(t *testing.T) UniversalBounds {
+	t.Helper()
+	var shapes []Config
+	for _, p := range []testProgram{squareProgram, chainProgram} {
+		_, cfg, _, _ := proveTestProgram(t, p, false)
+		shapes = append(shapes, ShapeParams(cfg))
+	}
+	return UniversalBounds{LogConstraints: 2, LogVars: 3, NonZeros: 3, Shapes: shapes}
+}
+
+func TestUniversalProvesProgramsOfDifferentSizes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("sets up a universal circuit")
+	}
+	bounds := universalBounds(t)
+	dir := t.TempDir()
+	var key string
+	for _, p := range []testProgram{squareProgram, chainProgram} {
+		proof, cfg, r1cs, interner := proveTestProgram(t, p, false)
+		v, err := NewVerifier(proof, cfg, r1cs, interner, WithUniversal(bounds))
+		if err != nil {
+			t.Fatal(err)
+		}
+		k, err := v.Key()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if key != "" && k != key {
+			t.Fatalf("programs are cached under %s and %s", key, k)
+		}
+		key = k
+		if err := v.SetupCached(dir); err != nil {
+			t.Fatal(err)
+		}
+		wrapper, err := v.Prove()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := v.Verify(wrapper); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// The verifiers of the shapes the proof does not have run on an empty proof,
+// so they only pass if every assertion they make, the standard library
+// gadgets included, goes through the selector.
+func TestUniversalGatesInactiveShapes(t *testing.T) {
+	bounds := universalBounds(t)
+	proof, cfg, r1cs, interner := proveTestProgram(t, chainProgram, false)
+	v, err := NewVerifier(proof, cfg, r1cs, interner, WithUniversal(bounds))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := isSolved(v); err != nil {
+		t.Fatal(err)
+	}
+
+	selector := v.assignment.ShapeSelector
+	v.assignment.ShapeSelector = []frontend.Variable{1, 0}
+	if err := isSolved(v); err == nil {
+		t.Fatal("the verifier of the other shape accepts the proof")
+	}
+	v.assignment.ShapeSelector = []frontend.Variable{1, 1}
+	if err := isSolved(v); err == nil {
+		t.Fatal("two shapes are selected")
+	}
+	v.assignment.ShapeSelector = selector
+
+	proof.MerklePaths[0].B[0][0].Limbs[0]++
+	v, err = NewVerifier(proof, cfg, r1cs, interner, WithUniversal(bounds))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := isSolved(v); err == nil {
+		t.Fatal("the universal circuit accepts a tampered leaf")
+	}
+}
@@ -0,0 +1,239 @@
+package whir
+
+import (
+	"errors"
+
+	"github.com/consensys/gnark/constraint"
+)
+
+// Verifier wraps a WHIR proof produced by ProveKit into a Groth16 or PLONK
+// proof of the WHIR verifier circuit.
+//
+// Compile and Setup produce the constraint system and the keys, which can be
+// saved and loaded instead of being recomputed. A Verifier created without
+// inputs can still load, set up and save these artifacts.
+type Verifier struct {
+	Proof    ProofObject
+	Config   Config
+	R1CS     R1CS
+	Interner Interner
+
+	settings   settings
+	circuit    Circuit
+	assignment Circuit
+
+	ccs constraint.ConstraintSystem
+	pk  ProvingKey
+	vk  VerifyingKey
+}
+
+// NewVerifier builds the verifier circuit and its witness for the given
+// inputs.
+func NewVerifier(proof ProofObject, cfg Config, r1cs R1CS, interner Interner, opts ...Option) (*Verifier, error) {
+	s := newSettings(opts)
+	circuit, assignment, err := buildCircuits(proof, cfg, r1cs, interner, s)
+	if err != nil {
+		return nil, WithStage(StageParse, err)
+	}
+	return &Verifier{
+		Proof:      proof,
+		Config:     cfg,
+		R1CS:       r1cs,
+		Interner:   interner,
+		settings:   s,
+		circuit:    circuit,
+		assignment: assignment,
+	}, nil
+}
+
+// NewVerifierFromFiles reads the proof, the params and the R1CS written by
+// the ProveKit prover and builds the verifier for them.
+func NewVerifierFromFiles(proofPath string, paramsPath string, r1csPath string, opts ...Option) (*Verifier, error) {
+	proof, cfg, r1cs, interner, err := loadInputs(proofPath, paramsPath, r1csPath)
+	if err != nil {
+		return nil, WithStage(StageParse, err)
+	}
+	return NewVerifier(proof, cfg, r1cs, interner, opts...)
+}
+
+// NewVerifierWithoutInputs returns a Verifier that can only load, set up and
+// save artifacts for the backend selected by opts.
+func NewVerifierWithoutInputs(opts ...Option) *Verifier {
+	return &Verifier{settings: newSettings(opts)}
+}
+
+// Backend returns the proof system the verifier proves with.
+func (v *Verifier) Backend() Backend {
+	if v.settings.Backend == "" {
+		return BackendGroth16
+	}
+	return v.settings.Backend
+}
+
+// Key returns the hash of the circuit shape of the inputs, under which the
+// artifacts are cached.
+func (v *Verifier) Key() (string, error) {
+	return circuitKey(v.Proof, v.Config, v.R1CS, v.settings)
+}
+
+func (v *Verifier) ConstraintSystem() constraint.ConstraintSystem {
+	return v.ccs
+}
+
+func (v *Verifier) ProvingKey() ProvingKey {
+	return v.pk
+}
+
+func (v *Verifier) VerifyingKey() VerifyingKey {
+	return v.vk
+}
+
+func (v *Verifier) Compile() error {
+	ccs, err := compileCircuit(&v.circuit, v.Backend())
+	if err != nil {
+		return err
+	}
+	v.ccs = ccs
+	return nil
+}
+
+func (v *Verifier) Setup() error {
+	if v.ccs == nil {
+		return WithStage(StageSetup, errors.New("circuit is not compiled"))
+	}
+	pk, vk, err := setupKeys(v.ccs, v.Backend())
+	if err != nil {
+		return err
+	}
+	v.pk, v.vk = pk, vk
+	return nil
+}
+
+func (v *Verifier) Prove() (Proof, error) {
+	if v.ccs == nil || v.pk == nil {
+		return nil, WithStage(StageProve, errors.New("circuit is not compiled or set up"))
+	}
+	return proveCircuit(v.ccs, v.pk, &v.assignment, v.Backend())
+}
+
+// Verify checks proof against the verifying key and the public inputs of the
+// WHIR proof the verifier was built for. A rejected proof is reported as a
+// VerifierError with StageVerify.
+func (v *Verifier) Verify(proof Proof) error {
+	if v.vk == nil {
+		return WithStage(StageVerify, errors.New("verifying key is not set up"))
+	}
+	return verifyProof(proof, v.vk, &v.assignment, v.Backend())
+}
+
+func (v *Verifier) SaveConstraintSystem(path string) error {
+	return WithStage(StageCompile, writeArtifact(path, v.ccs))
+}
+
+func (v *Verifier) LoadConstraintSystem(path string) error {
+	ccs := newConstraintSystem(v.Backend())
+	if err := readArtifact(path, ccs); err != nil {
+		return WithStage(StageCompile, err)
+	}
+	v.ccs = ccs
+	return nil
+}
+
+func (v *Verifier) SaveKeys(pkPath string, vkPath string) error {
+	if err := writeArtifact(pkPath, v.pk); err != nil {
+		return WithStage(StageSetup, err)
+	}
+	return WithStage(StageSetup, writeArtifact(vkPath, v.vk))
+}
+
+func (v *Verifier) LoadProvingKey(path string) error {
+	pk := newProvingKey(v.Backend())
+	if err := readArtifact(path, pk); err != nil {
+		return WithStage(StageSetup, err)
+	}
+	v.pk = pk
+	return nil
+}
+
+func (v *Verifier) LoadVerifyingKey(path string) error {
+	vk := newVerifyingKey(v.Backend())
+	if err := readArtifact(path, vk); err != nil {
+		return WithStage(StageSetup, err)
+	}
+	v.vk = vk
+	return nil
+}
+
+// CompileCached loads the constraint system from the cache in dir, compiling
+// the circuit and storing it there on a miss.
+func (v *Verifier) CompileCached(dir string) error {
+	paths, err := v.cachedArtifacts(dir)
+	if err != nil {
+		return WithStage(StageCompile, err)
+	}
+	ccs, err := loadOrCompile(paths.ccs, &v.circuit, v.Backend())
+	if err != nil {
+		return WithStage(StageCompile, err)
+	}
+	v.ccs = ccs
+	return nil
+}
+
+// SetupCached loads the constraint system and the keys from the cache in
+// dir, compiling and running the setup and storing the results on a miss.
+func (v *Verifier) SetupCached(dir string) error {
+	if err := v.CompileCached(dir); err != nil {
+		return err
+	}
+	paths, err := v.cachedArtifacts(dir)
+	if err != nil {
+		return WithStage(StageSetup, err)
+	}
+	pk, vk, err := loadOrSetup(paths.pk, paths.vk, v.ccs, v.Backend())
+	if err != nil {
+		return WithStage(StageSetup, err)
+	}
+	v.pk, v.vk = pk, vk
+	return nil
+}
+
+// LoadCachedVerifyingKey loads the verifying key from the cache in dir.
+func (v *Verifier) LoadCachedVerifyingKey(dir string) error {
+	paths, err := v.cachedArtifacts(dir)
+	if err != nil {
+		return WithStage(StageSetup, err)
+	}
+	return v.LoadVerifyingKey(paths.vk)
+}
+
+func (v *Verifier) cachedArtifacts(dir string) (artifactPaths, error) {
+	key, err := v.Key()
+	if err != nil {
+		return artifactPaths{}, err
+	}
+	return cachedArtifacts(dir, key)
+}
+
+// CountConstraints compiles the circuit for backend without keeping the
+// result and returns the number of constraints, to compare the backends.
+func (v *Verifier) CountConstraints(backend Backend) (int, error) {
+	ccs, err := compileCircuit(&v.circuit, backend)
+	if err != nil {
+		return 0, err
+	}
+	return ccs.GetNbConstraints(), nil
+}
+
+// WriteProof stores a proof produced by Prove.
+func WriteProof(path string, proof Proof) error {
+	return WithStage(StageProve, writeArtifact(path, proof))
+}
+
+// ReadProof loads a proof for the verifier's backend stored by WriteProof.
+func (v *Verifier) ReadProof(path string) (Proof, error) {
+	proof := newProof(v.Backend())
+	if err := readArtifact(path, proof); err != nil {
+		return nil, WithStage(StageParse, err)
+	}
+	return proof, nil
+}